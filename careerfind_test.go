@@ -1,10 +1,31 @@
-package main
+package careerfind
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
-	"regexp"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/gocolly/colly"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 func TestEmailRegex(t *testing.T) {
@@ -23,13 +44,12 @@ func TestEmailRegex(t *testing.T) {
 		{"invalid_special_chars", "user#@example.com", false},
 	}
 
-	emailRegex := regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := emailRegex.MatchString(tt.input)
+			// The whole input must be one address, not just contain one
+			got := emailRegex.FindString(tt.input) == tt.input
 			if got != tt.expected {
-				t.Errorf("emailRegex.MatchString(%q) = %v, want %v", tt.input, got, tt.expected)
+				t.Errorf("emailRegex matches all of %q = %v, want %v", tt.input, got, tt.expected)
 			}
 		})
 	}
@@ -45,4 +65,2555 @@ func TestResult_Validation(t *testing.T) {
 			name: "valid_result",
 			result: Result{
 				Emails:    []string{"test@example.com"},
-				Location:
+				Location:  "https://example.com/careers",
+				Timestamp: time.Now(),
+				Source:    "https://example.com/careers/job-posting",
+			},
+			wantErr: false,
+		},
+		{
+			name: "no_emails",
+			result: Result{
+				Location:  "https://example.com/careers",
+				Timestamp: time.Now(),
+				Source:    "https://example.com/careers",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_email",
+			result: Result{
+				Emails:    []string{"user@"},
+				Location:  "https://example.com/careers",
+				Timestamp: time.Now(),
+				Source:    "https://example.com/careers",
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing_source",
+			result: Result{
+				Emails:    []string{"test@example.com"},
+				Timestamp: time.Now(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing_timestamp",
+			result: Result{
+				Emails: []string{"test@example.com"},
+				Source: "https://example.com/careers",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.result.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Result.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newTestCrawler returns a Crawler with an in-memory database
+func newTestCrawler(t *testing.T, options ...Option) *Crawler {
+	t.Helper()
+	cr, err := New(options...)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	t.Cleanup(func() { cr.Close() })
+	return cr
+}
+
+func TestIdentifyTargetPages(t *testing.T) {
+	cr := newTestCrawler(t)
+	tests := []struct {
+		name      string
+		engines   string
+		wantPages int
+		wantErr   bool
+	}{
+		{"single_engine", "bing", 2, false},
+		{"multiple_engines", "google,duckduckgo", 3, false},
+		{"all_engines", "all", 5, false},
+		{"case_insensitive", "Bing", 2, false},
+		{"unknown_engine", "google,altavista", 0, true},
+		{"empty", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := cr.identifyTargetPages(context.Background(), tt.engines, false, []string{"Berlin"}, nil, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cr.identifyTargetPages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(pages) != tt.wantPages {
+				t.Errorf("cr.identifyTargetPages() returned %d pages, want %d", len(pages), tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestSelectorEngineSearchURL(t *testing.T) {
+	engine := &SelectorEngine{
+		EngineName:   "searxng",
+		BaseURL:      "https://searx.example.org/search",
+		ExtraParams:  "format=html",
+		PageParam:    "pageno",
+		FirstPage:    1,
+		PageStep:     1,
+		Pages:        3,
+		LinkSelector: "article.result h3 a",
+	}
+
+	tests := []struct {
+		page int
+		want string
+	}{
+		{0, "https://searx.example.org/search?q=email+careers+Berlin&format=html"},
+		{1, "https://searx.example.org/search?pageno=2&q=email+careers+Berlin&format=html"},
+		{2, "https://searx.example.org/search?pageno=3&q=email+careers+Berlin&format=html"},
+	}
+
+	for _, tt := range tests {
+		if got := engine.SearchURL("email careers Berlin", tt.page); got != tt.want {
+			t.Errorf("SearchURL(page %d) = %q, want %q", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestUnwrapRedirect(t *testing.T) {
+	tests := []struct {
+		link  string
+		param string
+		want  string
+	}{
+		{"https://www.google.com/url?q=https://acme.com/careers&sa=U", "q", "https://acme.com/careers"},
+		{"https://duckduckgo.com/l/?uddg=https%3A%2F%2Facme.com%2Fjobs&rut=abc", "uddg", "https://acme.com/jobs"},
+		{"https://www.google.com/search?q=careers", "q", "https://www.google.com/search?q=careers"},
+		{"https://acme.com/careers", "q", "https://acme.com/careers"},
+	}
+
+	for _, tt := range tests {
+		if got := unwrapRedirect(tt.link, tt.param); got != tt.want {
+			t.Errorf("unwrapRedirect(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+// fixtureTransport answers every request with the same HTML page
+type fixtureTransport string
+
+func (t fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader(string(t))), Request: req}, nil
+}
+
+func TestSearchEngineResultLinks(t *testing.T) {
+	tests := []struct {
+		engine string
+		page   string
+		want   []string
+	}{
+		{
+			engine: "google",
+			page: `<html><body>
+				<a href="https://accounts.google.com/ServiceLogin">Sign in</a>
+				<a href="https://policies.google.com/privacy">Privacy</a>
+				<a href="https://support.google.com/websearch">Help</a>
+				<a href="https://maps.google.com/maps?q=jobs+Berlin">Maps</a>
+				<a href="/search?q=jobs+Berlin&tbm=isch">Images</a>
+				<a href="/aclk?sa=l&adurl=https://ads.example.com/">Ad</a>
+				<div class="g"><a href="/url?q=https://acme.com/careers&sa=U">Acme careers</a></div>
+				<div class="g"><a href="/url?q=https://www.youtube.com/watch%3Fv%3Dabc&sa=U">Video</a></div>
+				<div class="g"><a href="/url?q=https://maps.google.com/maps%3Fq%3Dacme&sa=U">Map</a></div>
+				<div class="g"><a href="/url?q=https://jobs.globex.de/&sa=U">Globex jobs</a></div>
+				<div class="g"><a href="/url?q=https://acme.com/careers&sa=U">Acme again</a></div>
+			</body></html>`,
+			want: []string{"https://acme.com/careers", "https://jobs.globex.de/"},
+		},
+		{
+			engine: "bing",
+			page: `<html><body>
+				<a href="https://www.bing.com/images/search?q=jobs">Images</a>
+				<a href="https://go.microsoft.com/fwlink/?LinkId=521839">Privacy</a>
+				<ol id="b_results">
+					<li class="b_ad"><h2><a href="https://ads.example.com/">Ad</a></h2></li>
+					<li class="b_algo"><h2><a href="https://acme.com/careers">Acme careers</a></h2></li>
+					<li class="b_algo"><h2><a href="https://jobs.globex.de/">Globex jobs</a></h2></li>
+				</ol>
+			</body></html>`,
+			want: []string{"https://acme.com/careers", "https://jobs.globex.de/"},
+		},
+		{
+			engine: "duckduckgo",
+			page: `<html><body>
+				<div class="result result--ad"><a class="result__a" href="https://duckduckgo.com/y.js?ad_domain=ads.example.com&u3=https%3A%2F%2Fads.example.com">Ad</a></div>
+				<div class="result"><a class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Facme.com%2Fcareers&rut=abc">Acme careers</a></div>
+				<div class="result"><a class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Fjobs.globex.de%2F&rut=def">Globex jobs</a></div>
+				<a href="https://duckduckgo.com/settings">Settings</a>
+			</body></html>`,
+			want: []string{"https://acme.com/careers", "https://jobs.globex.de/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.engine, func(t *testing.T) {
+			resolved, err := resolveSearchEngines(tt.engine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := resolved[0]
+
+			c := colly.NewCollector()
+			c.WithTransport(fixtureTransport(tt.page))
+			var got []string
+			c.OnHTML("html", func(e *colly.HTMLElement) {
+				got = engine.ResultLinks(e)
+			})
+			if err := c.Visit(engine.SearchURL("jobs Berlin", 0)); err != nil {
+				t.Fatalf("Visit() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResultLinks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// serveAllHosts sends every request, whatever its host, to server until
+// the test ends. A TLS server's certificate is accepted for any host.
+func serveAllHosts(t *testing.T, server *httptest.Server) {
+	transport := http.DefaultTransport
+	http.DefaultTransport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	t.Cleanup(func() { http.DefaultTransport = transport })
+}
+
+func TestProcessPageStaysOnSite(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host + r.URL.Path {
+		case "acme.test/":
+			http.Redirect(w, r, "http://www.acme.test/", http.StatusMovedPermanently)
+		case "www.acme.test/":
+			w.Write([]byte(`<html><body>
+				<a href="http://jobs.acme.test/careers">Careers</a>
+				<a href="http://globex.test/careers">Partner jobs</a>
+				<a href="/careers/away">Old careers page</a>
+			</body></html>`))
+		case "www.acme.test/careers/away":
+			http.Redirect(w, r, "http://globex.test/jobs", http.StatusFound)
+		case "jobs.acme.test/careers":
+			w.Write([]byte(`<html><body>Apply at jobs@acme.test</body></html>`))
+		default:
+			w.Write([]byte(`<html><body>Write to spam@globex.test</body></html>`))
+		}
+	}))
+	defer server.Close()
+	serveAllHosts(t, server)
+
+	cr := newTestCrawler(t)
+	findings, err := cr.processPage(context.Background(), crawlTarget{URL: "http://acme.test/", Query: "Berlin"}, false, false)
+	if err != nil {
+		t.Fatalf("cr.processPage() error = %v", err)
+	}
+
+	var emails []string
+	for _, result := range findings.Results {
+		emails = append(emails, result.Emails...)
+	}
+	if want := []string{"jobs@acme.test"}; !reflect.DeepEqual(emails, want) {
+		t.Errorf("cr.processPage() found %q, want %q", emails, want)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("loadMigrations() returned no migrations")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %s has version %d, want %d", m.Name, m.Version, i+1)
+		}
+		if m.SQL == "" {
+			t.Errorf("migration %s is empty", m.Name)
+		}
+	}
+}
+
+func TestMigrateLegacyResults(t *testing.T) {
+	db, err := openDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A database from before schema_version, with comma-joined emails
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:2] {
+		if _, err := db.Exec(m.SQL); err != nil {
+			t.Fatalf("migration %s: %v", m.Name, err)
+		}
+	}
+	t1 := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	t2, t3 := t1.AddDate(0, 0, 1), t1.AddDate(0, 0, 2)
+	if _, err := db.Exec(`INSERT INTO crawl_runs (id, location, engines, status, started_at) VALUES (1, 'Berlin', 'bing', 'finished', ?)`, t1); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []struct {
+		emails, location, source string
+		seen                     time.Time
+		runID                    interface{}
+	}{
+		{"jobs@acme.com, hr@acme.com", "Berlin", "https://acme.com/careers", t1, 1},
+		{"hr@acme.com,", "Berlin", "https://acme.com/careers", t3, 1},
+		{"JOBS@acme.com", "Munich", "https://acme.com/jobs", t2, nil},
+		{"", "Munich", "https://acme.com/empty", t2, nil},
+		{"info@acme.com", "Munich", "", t2, nil},
+	} {
+		if _, err := db.Exec(`INSERT INTO results (emails, location, timestamp, source, run_id) VALUES (?, ?, ?, ?, ?)`,
+			row.emails, row.location, row.seen, row.source, row.runID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cr := &Crawler{config: DefaultConfig(), db: db, logger: log.New(io.Discard, "", 0)}
+	if err := cr.migrateDB(); err != nil {
+		t.Fatalf("migrateDB() error: %v", err)
+	}
+
+	for _, count := range []struct {
+		table string
+		want  int
+	}{
+		{"emails", 2},
+		{"sources", 2},
+		{"sightings", 3},
+		{"results_legacy", 5},
+	} {
+		var got int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + count.table).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != count.want {
+			t.Errorf("%s has %d row(s), want %d", count.table, got, count.want)
+		}
+	}
+
+	var first, last time.Time
+	if err := db.QueryRow(`SELECT si.first_seen, si.last_seen FROM sightings si JOIN emails e ON e.id = si.email_id
+		WHERE e.address = 'hr@acme.com' AND si.run_id = 1`).Scan(&first, &last); err != nil {
+		t.Fatalf("sighting of hr@acme.com in run 1: %v", err)
+	}
+	if !first.Equal(t1) || !last.Equal(t3) {
+		t.Errorf("hr@acme.com seen %v to %v, want %v to %v", first, last, t1, t3)
+	}
+
+	saved, err := cr.Results(ResultFilter{})
+	if err != nil {
+		t.Fatalf("Results() error: %v", err)
+	}
+	if len(saved) != 2 || saved[0].Location != "Berlin" || len(saved[0].Emails) != 2 || saved[1].Query != "Munich" {
+		t.Errorf("Results() after migration = %+v, want the Berlin page with 2 emails and the Munich one", saved)
+	}
+}
+
+func TestSaveResultsUpdatesSightings(t *testing.T) {
+	cr := newTestCrawler(t)
+	run, err := cr.startRun([]string{"Berlin"}, "bing", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	later := first.Add(6 * time.Hour)
+	for _, seen := range []time.Time{first, later} {
+		result := Result{Emails: []string{"jobs@acme.com"}, Location: "Berlin", Source: "https://acme.com/careers", Timestamp: seen}
+		if err := cr.saveResultsToDB(run, []Result{result}); err != nil {
+			t.Fatalf("saveResultsToDB() error: %v", err)
+		}
+	}
+
+	var sightings, emails int
+	if err := cr.db.QueryRow(`SELECT COUNT(*), (SELECT COUNT(*) FROM emails) FROM sightings`).Scan(&sightings, &emails); err != nil {
+		t.Fatal(err)
+	}
+	if sightings != 1 || emails != 1 {
+		t.Errorf("saving one email twice left %d sighting(s) and %d email(s), want 1 of each", sightings, emails)
+	}
+
+	for _, table := range []string{"sightings", "emails", "sources"} {
+		var firstSeen, lastSeen time.Time
+		if err := cr.db.QueryRow(`SELECT first_seen, last_seen FROM `+table).Scan(&firstSeen, &lastSeen); err != nil {
+			t.Fatal(err)
+		}
+		if !firstSeen.Equal(first) || !lastSeen.Equal(later) {
+			t.Errorf("%s seen %v to %v, want %v to %v", table, firstSeen, lastSeen, first, later)
+		}
+	}
+
+}
+
+func TestResultsRoundTrip(t *testing.T) {
+	cr := newTestCrawler(t)
+	runs := make([]*crawlRun, 2)
+	for i := range runs {
+		run, err := cr.startRun([]string{"Berlin", "Munich"}, "bing", nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs[i] = run
+	}
+
+	march := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	april := march.AddDate(0, 1, 0)
+	careers := Result{
+		Emails:    []string{"jobs@acme.com", "hr@eu.acme.com"},
+		Location:  "Berlin",
+		Query:     "careers Berlin",
+		Timestamp: march,
+		Source:    "https://acme.com/careers",
+		PageTitle: "Careers at Acme",
+		Details: map[string]EmailDetail{
+			"jobs@acme.com":  {Category: categoryCareers, Snippet: "Write to jobs@acme.com", Company: "acme.com", Verification: verifyValid},
+			"hr@eu.acme.com": {Category: categoryRecruiting, Heading: "Contact", Company: "acme.com", Verification: verifyUndeliverable},
+		},
+	}
+	contact := Result{
+		Emails:    []string{"info@globex.de"},
+		Location:  "Munich",
+		Query:     "careers Munich",
+		Timestamp: april,
+		Source:    "https://globex.de/kontakt",
+		Details:   map[string]EmailDetail{"info@globex.de": {Category: categoryContact, Company: "globex.de"}},
+	}
+	if err := cr.saveResultsToDB(runs[0], []Result{careers}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.saveResultsToDB(runs[1], []Result{contact}); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := cr.Results(ResultFilter{RunID: runs[0].ID})
+	if err != nil {
+		t.Fatalf("Results() error: %v", err)
+	}
+	if len(saved) != 1 {
+		t.Fatalf("Results() for run %d = %d result(s), want 1", runs[0].ID, len(saved))
+	}
+	saved[0].Timestamp = saved[0].Timestamp.UTC()
+	if !reflect.DeepEqual(saved[0], careers) {
+		t.Errorf("Results() = %+v, want %+v", saved[0], careers)
+	}
+
+	tests := []struct {
+		name   string
+		filter ResultFilter
+		want   []string
+	}{
+		{"all", ResultFilter{}, []string{"jobs@acme.com", "hr@eu.acme.com", "info@globex.de"}},
+		{"run", ResultFilter{RunID: runs[1].ID}, []string{"info@globex.de"}},
+		{"since", ResultFilter{Since: march.AddDate(0, 0, 7)}, []string{"info@globex.de"}},
+		{"domain and subdomains", ResultFilter{Domain: "acme.com"}, []string{"jobs@acme.com", "hr@eu.acme.com"}},
+		{"subdomain only", ResultFilter{Domain: "@eu.acme.com"}, []string{"hr@eu.acme.com"}},
+		{"location ignores case", ResultFilter{Location: "munich"}, []string{"info@globex.de"}},
+		{"company", ResultFilter{Company: "globex.de"}, []string{"info@globex.de"}},
+		{"categories", ResultFilter{Categories: []string{categoryRecruiting, categoryContact}}, []string{"hr@eu.acme.com", "info@globex.de"}},
+		{"no match", ResultFilter{Location: "Hamburg"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved, err := cr.Results(tt.filter)
+			if err != nil {
+				t.Fatalf("Results() error: %v", err)
+			}
+			var got []string
+			for _, result := range saved {
+				got = append(got, result.Emails...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Results(%+v) emails = %q, want %q", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	valid := SearchProfile{
+		Name:      "berlin",
+		Schedule:  "0 6 * * 1-5",
+		Locations: []string{"Berlin"},
+		Engines:   []string{"bing"},
+		Notify:    []string{"telegram", "telegram:-1001234"},
+	}
+
+	tests := []struct {
+		name         string
+		modify       func(p *SearchProfile)
+		wantProblems int
+	}{
+		{"valid", func(p *SearchProfile) {}, 0},
+		{"descriptor_schedule", func(p *SearchProfile) { p.Schedule = "@every 6h" }, 0},
+		{"all_engines", func(p *SearchProfile) { p.Engines = nil }, 0},
+		{"missing_name", func(p *SearchProfile) { p.Name = "" }, 1},
+		{"bad_schedule", func(p *SearchProfile) { p.Schedule = "daily" }, 1},
+		{"no_locations", func(p *SearchProfile) { p.Locations = nil }, 1},
+		{"unknown_engine", func(p *SearchProfile) { p.Engines = []string{"altavista"} }, 1},
+		{"bad_format", func(p *SearchProfile) { p.OutputFormat = "xml" }, 1},
+		{"bad_notify", func(p *SearchProfile) { p.Notify = []string{"email"} }, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.modify(&p)
+			if problems := validateProfiles([]SearchProfile{p}, nil); len(problems) != tt.wantProblems {
+				t.Errorf("validateProfiles() = %q, want %d problem(s)", problems, tt.wantProblems)
+			}
+		})
+	}
+
+	if problems := validateProfiles([]SearchProfile{valid, valid}, nil); len(problems) != 1 {
+		t.Errorf("validateProfiles() with duplicate names = %q, want 1 problem", problems)
+	}
+}
+
+func TestProfileJobSkipsWhileRunning(t *testing.T) {
+	var logged strings.Builder
+	cr := newTestCrawler(t, WithLogger(log.New(&logged, "", 0)))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := &profileJob{crawler: cr, ctx: ctx, profile: SearchProfile{Name: "berlin"}}
+
+	// The profile's previous search is still in progress
+	job.running.Lock()
+	job.Run()
+	job.running.Unlock()
+
+	if !strings.Contains(logged.String(), "Profile berlin is still running, skipping this search") {
+		t.Errorf("log = %q, want the search skipped", logged.String())
+	}
+}
+
+func TestProfileJobsRunOneAtATime(t *testing.T) {
+	cr := newTestCrawler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := &profileJob{crawler: cr, ctx: ctx, profile: SearchProfile{Name: "munich"}}
+
+	// Another profile's search holds the crawler
+	cr.searchMu.Lock()
+	done := make(chan struct{})
+	go func() {
+		job.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("profileJob.Run() did not wait for the other profile's search")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// A waiting search counts as running, so it is not started twice
+	if job.running.TryLock() {
+		t.Error("profileJob.Run() released the profile while waiting its turn")
+		job.running.Unlock()
+	}
+
+	cr.searchMu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("profileJob.Run() did not run after the other search ended")
+	}
+}
+
+func TestScheduleWaitsForSearchInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var startOnce, releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+
+	// Search pages hang until released
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "www.bing.com" {
+			startOnce.Do(func() { close(started) })
+			<-release
+		}
+		w.Write([]byte(`<html><body></body></html>`))
+	}))
+	defer server.Close()
+	defer unblock()
+	serveAllHosts(t, server)
+
+	cfg := DefaultConfig()
+	cfg.RateLimit = 1
+	cfg.OutputDir = t.TempDir()
+	cr := newTestCrawler(t, WithConfig(cfg), WithLogger(log.New(io.Discard, "", 0)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	profile := SearchProfile{Name: "berlin", Schedule: "@every 1s", Locations: []string{"Berlin"}, Engines: []string{"bing"}}
+	errc := make(chan error, 1)
+	go func() { errc <- cr.Schedule(ctx, []SearchProfile{profile}) }()
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the profile's search did not start")
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		t.Fatalf("Schedule() = %v while a search was still in progress", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unblock()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Schedule() error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Schedule() did not return after the search stopped")
+	}
+}
+
+func TestIdentifyTargetPagesLocations(t *testing.T) {
+	cr := newTestCrawler(t)
+	pages, err := cr.identifyTargetPages(context.Background(), "bing", true, []string{"Berlin", "San Francisco, CA"}, []string{"email careers {location}", "jobs {location}"}, false)
+	if err != nil {
+		t.Fatalf("cr.identifyTargetPages() error = %v", err)
+	}
+
+	// 2 templates x 2 bing pages + 1 LinkedIn page, per location
+	if len(pages) != 10 {
+		t.Fatalf("cr.identifyTargetPages() returned %d pages, want 10", len(pages))
+	}
+	for _, page := range pages[:5] {
+		if page.Location != "Berlin" {
+			t.Errorf("page %s has location %q, want Berlin", page.URL, page.Location)
+		}
+	}
+	if got := pages[5].Query; got != "email careers San Francisco, CA" {
+		t.Errorf("pages[5].Query = %q, want %q", got, "email careers San Francisco, CA")
+	}
+
+	if _, err := cr.identifyTargetPages(context.Background(), "bing", false, []string{"Berlin", ""}, nil, false); err == nil {
+		t.Error("cr.identifyTargetPages() with an empty location succeeded, want error")
+	}
+}
+
+func TestLoadLocations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cities.txt")
+	if err := os.WriteFile(file, []byte("# DACH\nBerlin\n\n  Vienna  \nzurich\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadLocations([]string{"Zurich", "@" + file, "berlin"})
+	if err != nil {
+		t.Fatalf("LoadLocations() error = %v", err)
+	}
+	want := []string{"Zurich", "Berlin", "Vienna"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadLocations() = %q, want %q", got, want)
+	}
+
+	if _, err := LoadLocations(nil); err == nil {
+		t.Error("LoadLocations(nil) succeeded, want error")
+	}
+	if _, err := LoadLocations([]string{"@" + file + ".missing"}); err == nil {
+		t.Error("LoadLocations() with a missing file succeeded, want error")
+	}
+}
+
+func TestGroupByLocation(t *testing.T) {
+	batch := []Result{
+		{Location: "Berlin", Source: "https://a.example/jobs"},
+		{Location: "Munich", Source: "https://b.example/jobs"},
+		{Location: "Berlin", Source: "https://c.example/jobs"},
+	}
+
+	groups := groupByLocation(batch)
+	if len(groups) != 2 {
+		t.Fatalf("groupByLocation() returned %d groups, want 2", len(groups))
+	}
+	if groups[0].Location != "Berlin" || len(groups[0].Results) != 2 {
+		t.Errorf("groups[0] = %s with %d results, want Berlin with 2", groups[0].Location, len(groups[0].Results))
+	}
+
+	var sources []string
+	for _, result := range SortByLocation(batch) {
+		sources = append(sources, result.Source)
+	}
+	want := []string{"https://a.example/jobs", "https://c.example/jobs", "https://b.example/jobs"}
+	if !reflect.DeepEqual(sources, want) {
+		t.Errorf("SortByLocation() sources = %q, want %q", sources, want)
+	}
+}
+
+func TestLocationsKeepTheirOwnFrontierAndSightings(t *testing.T) {
+	cr := newTestCrawler(t)
+	run, err := cr.startRun([]string{"Berlin", "Munich"}, "bing", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both locations surface the same page; crawling it for Berlin does
+	// not mark it done for Munich
+	for _, location := range []string{"Berlin", "Munich"} {
+		if err := cr.enqueueURL(run, frontierTarget, frontierEntry{URL: "https://acme.com/careers", Location: location}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cr.markURL(run, frontierEntry{URL: "https://acme.com/careers", Location: "Berlin"}, frontierDone, nil); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := cr.pendingURLs(run, frontierTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Location != "Munich" {
+		t.Errorf("cr.pendingURLs() = %+v, want the page for Munich only", pending)
+	}
+
+	seen := time.Now()
+	for _, location := range []string{"Berlin", "Munich"} {
+		result := Result{Emails: []string{"jobs@acme.com"}, Location: location, Source: "https://acme.com/careers", Timestamp: seen}
+		if err := cr.saveResultsToDB(run, []Result{result}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved, err := cr.Results(ResultFilter{RunID: run.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locations []string
+	for _, result := range saved {
+		locations = append(locations, result.Location)
+	}
+	if want := []string{"Berlin", "Munich"}; !reflect.DeepEqual(locations, want) {
+		t.Errorf("Results() locations = %q, want %q", locations, want)
+	}
+}
+
+func TestExpandQueryTemplates(t *testing.T) {
+	values := map[string][]string{
+		"role":    {"backend engineer", "SRE"},
+		"company": {"Acme"},
+		"domain":  {"acme.com"},
+	}
+
+	tests := []struct {
+		name      string
+		templates []string
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "location_only",
+			templates: []string{"email careers {location}"},
+			want:      []string{"email careers Berlin"},
+		},
+		{
+			name:      "cross_product",
+			templates: []string{"{role} jobs {location} {company}"},
+			want:      []string{"backend engineer jobs Berlin Acme", "SRE jobs Berlin Acme"},
+		},
+		{
+			name:      "repeated_placeholder",
+			templates: []string{"site:{domain} careers {location} \"@{domain}\""},
+			want:      []string{"site:acme.com careers Berlin \"@acme.com\""},
+		},
+		{
+			name:      "duplicates_dropped",
+			templates: []string{"careers {location}", "careers  {location}"},
+			want:      []string{"careers Berlin"},
+		},
+		{
+			name:      "missing_values",
+			templates: []string{"{team} jobs {location}"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandQueryTemplates(tt.templates, values, "Berlin")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandQueryTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandQueryTemplates() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateQueryTemplates(t *testing.T) {
+	values := map[string][]string{"role": {"designer"}}
+
+	tests := []struct {
+		template     string
+		wantProblems int
+	}{
+		{"{role} jobs {location}", 0},
+		{"{role} jobs", 0},
+		{"site:greenhouse.io careers", 0},
+		{"{company} jobs {location}", 1},
+		{"{company} {team} jobs", 2},
+	}
+
+	for _, tt := range tests {
+		if problems := validateQueryTemplates([]string{tt.template}, values); len(problems) != tt.wantProblems {
+			t.Errorf("validateQueryTemplates(%q) = %q, want %d problem(s)", tt.template, problems, tt.wantProblems)
+		}
+	}
+
+	cfg := Config{QueryValues: values}
+	if err := cfg.CheckQueryTemplates([]string{"{company} jobs"}); err == nil || !strings.Contains(err.Error(), "{company}") {
+		t.Errorf("CheckQueryTemplates(\"{company} jobs\") = %v, want an error naming {company}", err)
+	}
+}
+
+func TestClassifyEmail(t *testing.T) {
+	tests := []struct {
+		address string
+		context string
+		want    string
+	}{
+		{"jobs@acme.com", "", categoryCareers},
+		{"careers.de@acme.com", "", categoryCareers},
+		{"hr@acme.com", "", categoryRecruiting},
+		{"talent-acquisition@acme.com", "", categoryRecruiting},
+		{"recruiting2@acme.com", "", categoryRecruiting},
+		{"christine@acme.com", "", categoryOther},
+		{"noreply@acme.com", "", categoryNoReply},
+		{"do-not-reply@acme.com", "", categoryNoReply},
+		{"privacy@acme.com", "", categorySupport},
+		{"webmaster@acme.com", "", categorySupport},
+		{"info@acme.com", "", categoryContact},
+		{"hello@acme.com", "", categoryContact},
+		{"jane.doe@acme.com", "", categoryPersonal},
+		{"someone@gmail.com", "", categoryPersonal},
+		{"info@acme.com", "Send your CV and cover letter to info@acme.com", categoryRecruiting},
+		{"jane.doe@acme.com", "Questions about open positions? Contact jane.doe@acme.com", categoryCareers},
+		{"privacy@acme.com", "To apply, see our privacy notice at privacy@acme.com", categorySupport},
+		{"noreply@acme.com", "Our recruiting team sends updates from noreply@acme.com", categoryNoReply},
+	}
+
+	for _, tt := range tests {
+		if got := classifyEmail(tt.address, tt.context); got != tt.want {
+			t.Errorf("classifyEmail(%q, %q) = %q, want %q", tt.address, tt.context, got, tt.want)
+		}
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	got, err := ParseCategories("careers, HR,recruiting,")
+	if err != nil {
+		t.Fatalf("ParseCategories() error = %v", err)
+	}
+	if want := []string{categoryCareers, categoryRecruiting}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCategories() = %q, want %q", got, want)
+	}
+
+	if got, err := ParseCategories(""); err != nil || len(got) != 0 {
+		t.Errorf("ParseCategories(\"\") = %q, %v, want no categories", got, err)
+	}
+	if _, err := ParseCategories("careers,spam"); err == nil {
+		t.Error("ParseCategories() with an unknown category succeeded, want error")
+	}
+}
+
+func TestFilterCategories(t *testing.T) {
+	batch := []Result{
+		{Emails: []string{"jobs@acme.com", "noreply@acme.com"}, Source: "https://acme.com/careers"},
+		{Emails: []string{"support@acme.com"}, Source: "https://acme.com/help"},
+	}
+
+	got := filterCategories(batch, []string{categoryCareers})
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Emails, []string{"jobs@acme.com"}) {
+		t.Errorf("filterCategories() = %+v, want only jobs@acme.com", got)
+	}
+	if len(batch[0].Emails) != 2 {
+		t.Error("filterCategories() modified its input")
+	}
+}
+
+func TestExtractPageContext(t *testing.T) {
+	page := `<html><head><title>Careers - Acme</title></head><body>
+		<p>Support: help@acme.com</p>
+		<h1>Senior Backend Engineer (m/w/d)</h1>
+		<p>Send your CV to jobs@acme.com.</p>
+		<h2>Press</h2>
+		<p><a href="mailto:press@acme.com?subject=Hello">Write to our press team</a></p>
+		<h3>Contact</h3>
+		<ul><li>Questions? Ask hr@acme.com</li></ul>
+	</body></html>`
+	emails := []string{"help@acme.com", "jobs@acme.com", "press@acme.com", "hr@acme.com"}
+
+	c := colly.NewCollector()
+	c.WithTransport(fixtureTransport(page))
+	var got pageContext
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		got = extractPageContext(e, deobfuscateText(e.Text), emails)
+	})
+	if err := c.Visit("https://acme.com/careers"); err != nil {
+		t.Fatalf("Visit() error = %v", err)
+	}
+
+	if got.Title != "Careers - Acme" {
+		t.Errorf("Title = %q, want %q", got.Title, "Careers - Acme")
+	}
+	if got.JobTitle != "Senior Backend Engineer (m/w/d)" {
+		t.Errorf("JobTitle = %q, want %q", got.JobTitle, "Senior Backend Engineer (m/w/d)")
+	}
+
+	tests := []struct {
+		email   string
+		heading string
+		snippet string
+	}{
+		// Above the first heading
+		{"help@acme.com", "", "Support: help@acme.com"},
+		{"jobs@acme.com", "Senior Backend Engineer (m/w/d)", "Send your CV to jobs@acme.com."},
+		// Only in a mailto: link, so described by the link text
+		{"press@acme.com", "Press", "Write to our press team"},
+		{"hr@acme.com", "Contact", "Questions? Ask hr@acme.com"},
+	}
+
+	for _, tt := range tests {
+		if heading := got.Headings[tt.email]; heading != tt.heading {
+			t.Errorf("Headings[%q] = %q, want %q", tt.email, heading, tt.heading)
+		}
+		if snippet := got.Snippets[tt.email]; !strings.Contains(snippet, tt.snippet) {
+			t.Errorf("Snippets[%q] = %q, want it to contain %q", tt.email, snippet, tt.snippet)
+		}
+	}
+}
+
+func TestTextAround(t *testing.T) {
+	text := "Join us!\n\n  Apply to jobs@acme.com today.\n\n\n\nMore text"
+
+	if got, want := textAround(text, "jobs@acme.com", 11), "Apply to jobs@acme.com today."; got != want {
+		t.Errorf("textAround() = %q, want %q", got, want)
+	}
+	if got := textAround(text, "hr@acme.com", 10); got != "" {
+		t.Errorf("textAround() for a missing address = %q, want empty", got)
+	}
+	// The window starts inside Ü and is widened to keep the text valid UTF-8
+	if got, want := textAround("Grüß an jobs@acme.com", "an", 2), "ß an j"; got != want {
+		t.Errorf("textAround() = %q, want %q", got, want)
+	}
+}
+
+func TestFindJobTitle(t *testing.T) {
+	tests := []struct {
+		headings []string
+		title    string
+		want     string
+	}{
+		{[]string{"Careers at Acme", "Senior Backend Engineer (m/w/d)"}, "Acme", "Senior Backend Engineer (m/w/d)"},
+		{[]string{"About us", "Contact"}, "Data Analyst - Acme Careers", "Data Analyst - Acme Careers"},
+		{[]string{"Werkstudent Vertrieb"}, "", "Werkstudent Vertrieb"},
+		{[]string{"Welcome", "Our team"}, "Acme Inc.", ""},
+		{nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := findJobTitle(tt.headings, tt.title); got != tt.want {
+			t.Errorf("findJobTitle(%q, %q) = %q, want %q", tt.headings, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestMailtoAddress(t *testing.T) {
+	tests := []struct {
+		href string
+		want string
+	}{
+		{"mailto:jobs@acme.com", "jobs@acme.com"},
+		{"mailto:jobs@acme.com?subject=Application", "jobs@acme.com"},
+		{"MAILTO:jobs@acme.com", "jobs@acme.com"},
+		{"mailto:jobs%40acme.com", "jobs@acme.com"},
+		{"mailto:&#106;obs&#64;acme.com", "jobs@acme.com"},
+		{"mailto:jobs[at]acme[dot]com", "jobs@acme.com"},
+		{"https://acme.com/contact", ""},
+	}
+
+	for _, tt := range tests {
+		if got := mailtoAddress(tt.href); got != tt.want {
+			t.Errorf("mailtoAddress(%q) = %q, want %q", tt.href, got, tt.want)
+		}
+	}
+}
+
+func TestDeobfuscateText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "Write to jobs@acme.com today", "Write to jobs@acme.com today"},
+		{"bracketed at and dot", "jobs [at] acme [dot] com", "jobs@acme.com"},
+		{"parenthesized at", "jobs(at)acme.com", "jobs@acme.com"},
+		{"braces", "jobs {at} acme {dot} co {dot} uk", "jobs@acme.co.uk"},
+		{"bracketed symbols", "jobs[@]acme[.]com", "jobs@acme.com"},
+		{"angle brackets", "jobs <at> acme <dot> com", "jobs@acme.com"},
+		{"uppercase", "Jobs [AT] Acme [DOT] com", "Jobs@Acme.com"},
+		{"mixed dots", "hr(at)mail.acme[dot]de", "hr@mail.acme.de"},
+		{"bracketed at with spelled dot", "jobs [at] acme dot com", "jobs@acme.com"},
+		{"in a sentence", "Send your CV to jobs [at] acme [dot] com.", "Send your CV to jobs@acme.com."},
+		{"prose with at", "meet us at acme.com", "meet us at acme.com"},
+		{"prose with at and dot", "write to us at acme dot com", "write to us at acme dot com"},
+		{"prose with bracketed dot", "find us at acme [dot] com", "find us at acme [dot] com"},
+		{"prose with at sign", "reach the team @ acme.com", "reach the team @ acme.com"},
+		{"prose about dots", "look at the dot matrix", "look at the dot matrix"},
+		{"decimal entities", "&#106;obs&#64;acme&#46;com", "jobs@acme.com"},
+		{"hex entities", "jobs&#x40;acme.com", "jobs@acme.com"},
+		{"double encoded", "jobs&amp;#64;acme.com", "jobs&#64;acme.com"},
+		{"named entities", "jobs&commat;acme&period;com", "jobs@acme.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deobfuscateText(tt.text); got != tt.want {
+				t.Errorf("deobfuscateText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCloudflareEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		want    string
+	}{
+		{"valid", "42282d20310223212f276c212d2f", "jobs@acme.com"},
+		{"uppercase hex", "42282D20310223212F276C212D2F", "jobs@acme.com"},
+		{"not hex", "zz", ""},
+		{"key only", "42", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeCloudflareEmail(tt.encoded); got != tt.want {
+				t.Errorf("decodeCloudflareEmail(%q) = %q, want %q", tt.encoded, got, tt.want)
+			}
+		})
+	}
+
+	href := "/cdn-cgi/l/email-protection#42282d20310223212f276c212d2f"
+	if got := cloudflareLinkEmail(href); got != "jobs@acme.com" {
+		t.Errorf("cloudflareLinkEmail(%q) = %q, want %q", href, got, "jobs@acme.com")
+	}
+	if got := cloudflareLinkEmail("https://acme.com/contact"); got != "" {
+		t.Errorf("cloudflareLinkEmail() = %q, want empty", got)
+	}
+}
+
+func TestCheckEmailSyntax(t *testing.T) {
+	tests := []struct {
+		address string
+		valid   bool
+	}{
+		{"jobs@acme.com", true},
+		{"first.last+tag@mail.acme.co.uk", true},
+		{"o'brien@acme.ie", true},
+		{"jobs@xn--mller-kva.de", true},
+		{"jobs@acme", false},
+		{".jobs@acme.com", false},
+		{"jobs.@acme.com", false},
+		{"jo..bs@acme.com", false},
+		{"jobs@-acme.com", false},
+		{"jobs@acme-.com", false},
+		{"jobs@acme..com", false},
+		{"jobs@acme.c0m", false},
+		{"jobs@acme.c", false},
+		{"jobs@[192.0.2.1]", false},
+		{"@acme.com", false},
+		{"jobs@", false},
+		{strings.Repeat("a", 65) + "@acme.com", false},
+		{"jobs@" + strings.Repeat("a", 64) + ".com", false},
+	}
+
+	for _, tt := range tests {
+		if err := checkEmailSyntax(tt.address); (err == nil) != tt.valid {
+			t.Errorf("checkEmailSyntax(%q) = %v, want valid %v", tt.address, err, tt.valid)
+		}
+	}
+}
+
+func TestIsAssetName(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"image@2x.png", true},
+		{"logo@3x.webp", true},
+		{"icon-sprite@2x.svg", true},
+		{"app.bundle@1.2.3.js", true},
+		{"jobs@acme.com", false},
+		{"jobs@acme.jobs", false},
+		{"hr@acme.io", false},
+	}
+
+	for _, tt := range tests {
+		if got := isAssetName(tt.address); got != tt.want {
+			t.Errorf("isAssetName(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+// startFakeDNS serves the given MX and A records over UDP on localhost and
+// returns a resolver using it. Names without records do not exist; "." as
+// an MX host is a null MX.
+func startFakeDNS(t *testing.T, mx map[string][]string, a map[string][]string) *net.Resolver {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake DNS server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply, err := fakeDNSReply(buf[:n], mx, a); err == nil {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+}
+
+func fakeDNSReply(query []byte, mx map[string][]string, a map[string][]string) ([]byte, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(q.Name.String()), ".")
+	reply := dnsmessage.Header{ID: header.ID, Response: true, Authoritative: true, RecursionAvailable: true}
+	if mx[name] == nil && a[name] == nil {
+		reply.RCode = dnsmessage.RCodeNameError
+	}
+
+	b := dnsmessage.NewBuilder(nil, reply)
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+
+	rh := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 60}
+	switch q.Type {
+	case dnsmessage.TypeMX:
+		for i, host := range mx[name] {
+			if host != "." {
+				host += "."
+			}
+			if err := b.MXResource(rh, dnsmessage.MXResource{Pref: uint16(10 * (i + 1)), MX: dnsmessage.MustNewName(host)}); err != nil {
+				return nil, err
+			}
+		}
+	case dnsmessage.TypeA:
+		for _, ip := range a[name] {
+			var addr [4]byte
+			copy(addr[:], net.ParseIP(ip).To4())
+			if err := b.AResource(rh, dnsmessage.AResource{A: addr}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.Finish()
+}
+
+// failingResolver fails every lookup as if the DNS server timed out
+type failingResolver struct{}
+
+func (failingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return nil, &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+}
+
+func (failingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+}
+
+func TestEmailVerifier(t *testing.T) {
+	resolver := startFakeDNS(t,
+		map[string][]string{
+			"acme.com":       {"mx1.acme.com", "mx2.acme.com"},
+			"parked.example": {"."},
+		},
+		map[string][]string{
+			"startup.io":     {"192.0.2.10"},
+			"parked.example": {"192.0.2.20"},
+		},
+	)
+	v := newEmailVerifier(resolver, 2*time.Second, log.New(io.Discard, "", 0))
+
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"jobs@acme.com", verifyValid},
+		{"HR@ACME.COM", verifyValid},
+		{"hello@startup.io", verifyNoMX},
+		{"info@parked.example", verifyUndeliverable},
+		{"jobs@nowhere.example", verifyUndeliverable},
+		{"image@2x.png", verifyInvalid},
+		{"jo..bs@acme.com", verifyInvalid},
+	}
+
+	for _, tt := range tests {
+		if got := v.verify(context.Background(), tt.address); got != tt.want {
+			t.Errorf("verify(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+
+	failing := newEmailVerifier(failingResolver{}, time.Second, log.New(io.Discard, "", 0))
+	if got := failing.verify(context.Background(), "jobs@acme.com"); got != verifyUnknown {
+		t.Errorf("verify() with failing resolver = %q, want %q", got, verifyUnknown)
+	}
+	if _, cached := failing.domains["acme.com"]; cached {
+		t.Error("failed lookup was cached")
+	}
+}
+
+// staticResolver answers MX lookups from a map of domains to mail hosts;
+// other names do not exist
+type staticResolver map[string][]string
+
+func (r staticResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	var records []*net.MX
+	for _, host := range r[strings.TrimSuffix(name, ".")] {
+		records = append(records, &net.MX{Host: host, Pref: 10})
+	}
+	if len(records) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func (r staticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestWithResolver(t *testing.T) {
+	cr := newTestCrawler(t, WithResolver(staticResolver{"acme.com": {"mx.acme.com."}}))
+
+	if got := cr.verifier.verify(context.Background(), "jobs@acme.com"); got != verifyValid {
+		t.Errorf("verify(jobs@acme.com) = %q, want %q", got, verifyValid)
+	}
+	if got := cr.verifier.verify(context.Background(), "jobs@nowhere.example"); got != verifyUndeliverable {
+		t.Errorf("verify(jobs@nowhere.example) = %q, want %q", got, verifyUndeliverable)
+	}
+}
+
+func TestEmailCompany(t *testing.T) {
+	tests := []struct {
+		email  string
+		source string
+		want   string
+	}{
+		{"jobs@acme.com", "https://acme.com/careers", "acme.com"},
+		{"hr@eu.acme.com", "https://acme.com/careers", "acme.com"},
+		{"jobs@acme.co.uk", "https://careers.acme.co.uk/", "acme.co.uk"},
+		{"Jobs@ACME.com", "https://example.org", "acme.com"},
+		{"acme.recruiting@gmail.com", "https://www.acme.com/jobs", "acme.com"},
+		{"jane@gmail.com", "https://192.0.2.1/jobs", "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		if got := emailCompany(tt.email, tt.source); got != tt.want {
+			t.Errorf("emailCompany(%q, %q) = %q, want %q", tt.email, tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestCompanyName(t *testing.T) {
+	tests := map[string]string{
+		"acme.com":         "Acme",
+		"acme-corp.co.uk":  "Acme Corp",
+		"192.0.2.1":        "192.0.2.1",
+		"xn--mller-kva.de": "xn--mller-kva.de",
+	}
+	for domain, want := range tests {
+		if got := companyName(domain); got != want {
+			t.Errorf("companyName(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
+
+func TestGroupByCompany(t *testing.T) {
+	batch := []Result{
+		{Emails: []string{"jobs@zeta.io", "hr@acme.com"}, Location: "Berlin", Source: "https://jobs.example/1"},
+		{Emails: []string{"careers@eu.acme.com"}, Location: "Paris", Source: "https://acme.com/careers"},
+		{Emails: []string{"hr@acme.com"}, Location: "Paris", Source: "https://acme.com/jobs"},
+	}
+
+	companies := GroupByCompany(batch)
+
+	var domains []string
+	for _, company := range companies {
+		domains = append(domains, company.Domain)
+	}
+	if want := []string{"acme.com", "zeta.io"}; !reflect.DeepEqual(domains, want) {
+		t.Fatalf("company domains = %v, want %v", domains, want)
+	}
+
+	acme := companies[0]
+	if acme.Name != "Acme" {
+		t.Errorf("company name = %q, want %q", acme.Name, "Acme")
+	}
+	if want := []string{"hr@acme.com", "careers@eu.acme.com"}; !reflect.DeepEqual(acme.Emails, want) {
+		t.Errorf("acme emails = %v, want %v", acme.Emails, want)
+	}
+	if len(acme.Results) != 3 || !reflect.DeepEqual(acme.Results[0].Emails, []string{"hr@acme.com"}) {
+		t.Errorf("acme results = %+v, want 3 with only acme emails", acme.Results)
+	}
+
+	kept := filterCompany(batch, "careers.acme.com")
+	if len(kept) != 3 || !reflect.DeepEqual(kept[0].Emails, []string{"hr@acme.com"}) {
+		t.Errorf("filterCompany() = %+v, want acme emails only", kept)
+	}
+}
+
+func TestParseJSONLDJobPostings(t *testing.T) {
+	source := "https://careers.acme.com/jobs/42"
+	tests := []struct {
+		name string
+		data string
+		want []JobPosting
+	}{
+		{
+			name: "full posting",
+			data: `{
+				"@context": "https://schema.org/",
+				"@type": "JobPosting",
+				"title": "Backend Engineer (m/w/d)",
+				"datePosted": "2025-03-01T09:00:00+01:00",
+				"employmentType": "FULL_TIME",
+				"url": "https://careers.acme.com/jobs/42",
+				"hiringOrganization": {"@type": "Organization", "name": "Acme GmbH", "url": "https://www.acme.de"},
+				"jobLocation": {"@type": "Place", "address": {"@type": "PostalAddress", "addressLocality": "Berlin", "addressCountry": "DE"}},
+				"applicantContact": {"@type": "ContactPoint", "email": "mailto:jobs@acme.de"}
+			}`,
+			want: []JobPosting{{
+				Title:          "Backend Engineer (m/w/d)",
+				Organization:   "Acme GmbH",
+				Company:        "acme.de",
+				Location:       "Berlin, DE",
+				DatePosted:     "2025-03-01",
+				EmploymentType: "FULL_TIME",
+				ContactEmail:   "jobs@acme.de",
+				URL:            "https://careers.acme.com/jobs/42",
+				Source:         source,
+			}},
+		},
+		{
+			name: "graph with several locations",
+			data: `{"@context": "https://schema.org", "@graph": [
+				{"@type": "WebPage", "name": "Jobs"},
+				{"@type": ["JobPosting"], "title": "Sales &amp; Marketing Lead", "hiringOrganization": "Acme",
+				 "jobLocation": [
+					{"address": {"addressLocality": "Paris", "addressCountry": {"@type": "Country", "name": "France"}}},
+					{"address": {"addressLocality": "Lyon", "addressCountry": "France"}}
+				 ],
+				 "datePosted": "2025-02-14"}
+			]}`,
+			want: []JobPosting{{
+				Title:        "Sales & Marketing Lead",
+				Organization: "Acme",
+				Company:      "acme.com",
+				Location:     "Paris, France; Lyon, France",
+				DatePosted:   "2025-02-14",
+				Source:       source,
+			}},
+		},
+		{
+			name: "array of remote postings",
+			data: `[
+				{"@type": "JobPosting", "title": "Support Engineer", "jobLocationType": "TELECOMMUTE",
+				 "hiringOrganization": {"name": "Zeta", "email": "people@zeta.io"}},
+				{"@type": "http://schema.org/JobPosting", "name": "Data Analyst", "datePosted": "yesterday"}
+			]`,
+			want: []JobPosting{
+				{Title: "Support Engineer", Organization: "Zeta", Company: "zeta.io", Location: "Remote", ContactEmail: "people@zeta.io", Source: source},
+				{Title: "Data Analyst", Company: "acme.com", Source: source},
+			},
+		},
+		{
+			name: "no title",
+			data: `{"@type": "JobPosting", "hiringOrganization": "Acme"}`,
+		},
+		{
+			name: "other types",
+			data: `{"@type": "Organization", "name": "Acme", "email": "info@acme.com"}`,
+		},
+		{
+			name: "malformed",
+			data: `{"@type": "JobPosting", "title": "Engineer",}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseJSONLDJobPostings(tt.data, source)
+			for i := range got {
+				got[i].Timestamp = time.Time{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONLDJobPostings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractJobPostingsMicrodata(t *testing.T) {
+	page := `<html><body>
+		<div itemscope itemtype="https://schema.org/JobPosting">
+			<h1 itemprop="title">Backend Engineer</h1>
+			<div itemprop="hiringOrganization" itemscope itemtype="https://schema.org/Organization">
+				<span itemprop="name">Acme</span>
+				<a itemprop="url" href="https://acme.com/">acme.com</a>
+			</div>
+			<div itemprop="jobLocation" itemscope itemtype="https://schema.org/Place">
+				<div itemprop="address" itemscope itemtype="https://schema.org/PostalAddress">
+					<span itemprop="addressLocality">Berlin</span>
+					<meta itemprop="addressCountry" content="DE">
+				</div>
+			</div>
+			<time itemprop="datePosted" datetime="2024-03-01T09:00">March 1</time>
+			<a itemprop="url" href="https://careers.acme.com/jobs/42">Apply</a>
+		</div>
+		<div itemscope itemtype="https://schema.org/JobPosting">
+			<h2 itemprop="title">Designer</h2>
+			<div itemprop="hiringOrganization" itemscope itemtype="https://schema.org/Organization">
+				<span itemprop="name">Acme</span>
+				<a itemprop="url" href="https://acme.com/">acme.com</a>
+			</div>
+		</div>
+	</body></html>`
+	source := "https://careers.acme.com/jobs"
+
+	c := colly.NewCollector()
+	c.WithTransport(fixtureTransport(page))
+	var got []JobPosting
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		got = extractJobPostings(e, source)
+	})
+	if err := c.Visit(source); err != nil {
+		t.Fatalf("Visit() error = %v", err)
+	}
+	for i := range got {
+		got[i].Timestamp = time.Time{}
+	}
+
+	// The postings' url is their own, never that of the organization
+	want := []JobPosting{
+		{Title: "Backend Engineer", Organization: "Acme", Company: "acme.com", Location: "Berlin, DE", DatePosted: "2024-03-01", URL: "https://careers.acme.com/jobs/42", Source: source},
+		{Title: "Designer", Organization: "Acme", Company: "acme.com", Source: source},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractJobPostings() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	tests := map[string]string{
+		"2025-03-01":                   "2025-03-01",
+		"2025-03-01T09:00:00Z":         "2025-03-01",
+		"2025-03-01T09:00":             "2025-03-01",
+		"2025-03-01T09:00:00.000+0100": "2025-03-01",
+		"March 1, 2025":                "",
+		"":                             "",
+	}
+	for value, want := range tests {
+		if got := normalizeDate(value); got != want {
+			t.Errorf("normalizeDate(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestRobotsPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/", "/careers", true},
+		{"/careers", "/careers/engineer", true},
+		{"/careers", "/about", false},
+		{"/*.pdf$", "/files/jobs.pdf", true},
+		{"/*.pdf$", "/files/jobs.pdf?download=1", false},
+		{"/careers$", "/careers", true},
+		{"/careers$", "/careers/", false},
+		{"/*/apply", "/jobs/42/apply", true},
+		{"/*?session=", "/jobs?session=abc", true},
+		{"/*?session=", "/jobs", false},
+	}
+
+	for _, tt := range tests {
+		if got := robotsPatternMatch(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsPatternMatch(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseRobotsTxt(t *testing.T) {
+	data := `# robots.txt for acme.com
+User-agent: *
+Disallow: /admin
+Disallow: /careers/internal
+Allow: /careers/internal/open-day
+Crawl-delay: 2
+
+User-agent: Googlebot
+User-agent: bingbot
+Disallow:
+
+User-agent: careerfind
+Disallow: /jobs/
+Crawl-delay: 0.5
+`
+
+	wildcard := parseRobotsTxt(data, "otherbot")
+	if wildcard.crawlDelay != 2*time.Second {
+		t.Errorf("* crawl delay = %v, want 2s", wildcard.crawlDelay)
+	}
+	named := parseRobotsTxt(data, robotsAgent)
+	if named.crawlDelay != 500*time.Millisecond {
+		t.Errorf("careerfind crawl delay = %v, want 500ms", named.crawlDelay)
+	}
+	if google := parseRobotsTxt(data, "googlebot"); !google.allowed("/admin") {
+		t.Error("empty Disallow should allow everything")
+	}
+
+	tests := []struct {
+		rules robotsRules
+		path  string
+		want  bool
+	}{
+		{wildcard, "/careers", true},
+		{wildcard, "/admin/users", false},
+		{wildcard, "/careers/internal/roles", false},
+		{wildcard, "/careers/internal/open-day", true},
+		{wildcard, "/robots.txt", true},
+		{named, "/jobs/42", false},
+		{named, "/admin", true},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.allowed(tt.path); got != tt.want {
+			t.Errorf("allowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsFromResponse(t *testing.T) {
+	tests := []struct {
+		status    int
+		path      string
+		want      bool
+		unreached bool
+	}{
+		{200, "/private", false, false},
+		{200, "/careers", true, false},
+		{404, "/private", true, false},
+		{403, "/private", true, false},
+		{503, "/careers", false, true},
+		{0, "/careers", false, true},
+	}
+
+	for _, tt := range tests {
+		rules := robotsFromResponse(tt.status, "User-agent: *\nDisallow: /private\n", "careerfind")
+		if got := rules.allowed(tt.path); got != tt.want || rules.unreachable != tt.unreached {
+			t.Errorf("status %d: allowed(%q) = %v (unreachable %v), want %v (unreachable %v)",
+				tt.status, tt.path, got, rules.unreachable, tt.want, tt.unreached)
+		}
+	}
+}
+
+func TestRobotsCache(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	cache := newRobotsCache(func(robotsURL string, proxyEnabled bool) (int, string) {
+		mu.Lock()
+		fetches[robotsURL]++
+		mu.Unlock()
+		if strings.Contains(robotsURL, "down.example") {
+			return 0, ""
+		}
+		return 200, "User-agent: *\nDisallow: /private\nCrawl-delay: 0.05\n"
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, _ := url.Parse("https://acme.com/careers")
+			cache.check(u, false)
+		}()
+	}
+	wg.Wait()
+	if n := fetches["https://acme.com/robots.txt"]; n != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1", n)
+	}
+
+	tests := []struct {
+		url    string
+		ok     bool
+		reason string
+	}{
+		{"https://acme.com/careers?page=2", true, ""},
+		{"https://acme.com/private/jobs", false, skipDisallowed},
+		{"https://down.example/careers", false, skipNoRobots},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if ok, reason := cache.check(u, false); ok != tt.ok || reason != tt.reason {
+			t.Errorf("check(%s) = %v, %q, want %v, %q", tt.url, ok, reason, tt.ok, tt.reason)
+		}
+	}
+
+	u, _ := url.Parse("https://acme.com/careers")
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		cache.wait(context.Background(), u, false)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests with a 50ms crawl delay took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestValidateHostLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   []HostLimit
+		problems int
+	}{
+		{"valid", []HostLimit{{Domain: "*.linkedin.com", Parallelism: 1, DelayMs: 5000}, {Domain: "*", Parallelism: 2, RandomDelayMs: 500}}, 0},
+		{"no limits", nil, 0},
+		{"empty domain", []HostLimit{{Parallelism: 1}}, 1},
+		{"bad glob", []HostLimit{{Domain: "[acme.com"}}, 1},
+		{"negative values", []HostLimit{{Domain: "*", Parallelism: -1, DelayMs: -5}}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateHostLimits(tt.limits); len(got) != tt.problems {
+				t.Errorf("validateHostLimits() = %v, want %d problem(s)", got, tt.problems)
+			}
+		})
+	}
+}
+
+// slowTransport counts the requests it is serving at once
+type slowTransport struct {
+	mu      sync.Mutex
+	current int
+	peak    int
+}
+
+func (t *slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.current++
+	if t.current > t.peak {
+		t.peak = t.current
+	}
+	t.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	t.mu.Lock()
+	t.current--
+	t.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Request: req}, nil
+}
+
+func TestLimitedTransport(t *testing.T) {
+	base := &slowTransport{}
+	transport := &limitedTransport{base: base, slots: make(chan struct{}, 2)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "https://acme.com/careers", nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Errorf("RoundTrip() error = %v", err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if base.peak > 2 {
+		t.Errorf("peak concurrent requests = %d, want at most 2", base.peak)
+	}
+	if n := len(transport.slots); n != 0 {
+		t.Errorf("%d request slot(s) still held after all bodies were closed", n)
+	}
+}
+
+func TestHostLimitsAcrossCollectors(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	current, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		current++
+		if current > peak {
+			peak = current
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	slots := make(chan struct{}, 5)
+	hosts := newHostLimiter([]HostLimit{{Domain: "127.0.0.1", Parallelism: 1, DelayMs: 100}})
+
+	// Two targets on one host, each crawled by its own collector
+	var wg sync.WaitGroup
+	for _, page := range []string{"/careers", "/jobs"} {
+		c := colly.NewCollector()
+		c.WithTransport(&limitedTransport{base: http.DefaultTransport, slots: slots, hosts: hosts})
+		wg.Add(1)
+		go func(page string) {
+			defer wg.Done()
+			if err := c.Visit(server.URL + page); err != nil {
+				t.Errorf("Visit(%s) error: %v", page, err)
+			}
+		}(page)
+	}
+	wg.Wait()
+
+	if len(starts) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(starts))
+	}
+	if peak > 1 {
+		t.Errorf("peak concurrent requests to host = %d, want 1", peak)
+	}
+	gap := starts[1].Sub(starts[0])
+	if gap < 90*time.Millisecond {
+		t.Errorf("requests to host started %v apart, want at least the 100ms delay", gap)
+	}
+}
+
+func TestCrawlAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		denied  []string
+		url     string
+		want    bool
+	}{
+		{"no lists", nil, nil, "https://acme.com/jobs", true},
+		{"allowed domain", []string{"acme.com"}, nil, "https://acme.com/jobs", true},
+		{"allowed subdomain", []string{"acme.com"}, nil, "https://careers.acme.com/", true},
+		{"not allowed", []string{"acme.com"}, nil, "https://notacme.com/", false},
+		{"denied", nil, []string{"indeed.com"}, "https://de.indeed.com/jobs", false},
+		{"denied wins", []string{"acme.com"}, []string{"eu.acme.com"}, "https://jobs.eu.acme.com/", false},
+		{"case and port", []string{"Acme.com"}, nil, "https://JOBS.acme.com:8443/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{AllowedDomains: tt.allowed, DeniedDomains: tt.denied}
+			if got := cfg.crawlAllowed(tt.url); got != tt.want {
+				t.Errorf("crawlAllowed(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueTargetAppliesDomainFilters(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DeniedDomains = []string{"linkedin.com"}
+	cr := newTestCrawler(t, WithConfig(cfg))
+
+	run, err := cr.startRun([]string{"Berlin"}, "google", nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// LinkedIn pages are crawled without a search engine, but are filtered
+	// like any search result
+	pages := []searchPage{{URL: "https://www.linkedin.com/jobs/search?location=Berlin", Location: "Berlin"}}
+	if err := cr.extractEmails(context.Background(), run, pages, false, false, func(Result) {}); err != nil {
+		t.Fatalf("extractEmails() error = %v", err)
+	}
+
+	if pending, err := cr.pendingURLs(run, frontierTarget); err != nil || len(pending) != 0 {
+		t.Errorf("pendingURLs() = %v, %v; want no targets", pending, err)
+	}
+	skipped, err := cr.SkippedURLs(run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []SkippedURL{{RunID: run.ID, URL: pages[0].URL, Reason: skipDomainFilter}}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("SkippedURLs() = %v, want %v", skipped, want)
+	}
+}
+
+func TestValidateDomainLists(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		denied   []string
+		problems int
+	}{
+		{"valid", []string{"acme.com"}, []string{"indeed.com"}, 0},
+		{"empty entry", []string{""}, nil, 1},
+		{"url instead of domain", nil, []string{"https://indeed.com"}, 1},
+		{"glob", []string{"*.acme.com"}, nil, 1},
+		{"allowed and denied", []string{"acme.com"}, []string{"ACME.com"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateDomainLists(tt.allowed, tt.denied); len(got) != tt.problems {
+				t.Errorf("validateDomainLists() = %v, want %d problem(s)", got, tt.problems)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	t.Setenv("SEARCH_DEPTH", "4")
+	t.Setenv("DENIED_DOMAINS", "indeed.com, glassdoor.com")
+	t.Setenv("DB_PATH", "/tmp/other.db")
+	config, _, _ := LoadConfig("config.json")
+
+	if config.SearchDepth != 4 {
+		t.Errorf("SearchDepth = %d, want 4 from the environment", config.SearchDepth)
+	}
+	if want := []string{"indeed.com", "glassdoor.com"}; !reflect.DeepEqual(config.DeniedDomains, want) {
+		t.Errorf("DeniedDomains = %q, want %q", config.DeniedDomains, want)
+	}
+	if config.DBPath != "/tmp/other.db" || config.LogPath != defaultLogPath {
+		t.Errorf("DBPath, LogPath = %q, %q; want the environment and default values", config.DBPath, config.LogPath)
+	}
+
+}
+
+func TestCrawlerRun(t *testing.T) {
+	cr := newTestCrawler(t)
+	if saved, err := cr.Results(ResultFilter{}); err != nil || len(saved) != 0 {
+		t.Errorf("Results() on a new Crawler = %d result(s), %v; want none", len(saved), err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.SearchDepth = 0
+	cr = newTestCrawler(t, WithConfig(cfg))
+	_, err := cr.Run(context.Background(), Query{Locations: []string{"Berlin"}})
+	if err == nil || !strings.Contains(err.Error(), "search_depth") {
+		t.Errorf("Run() with an invalid config = %v, want a search_depth error", err)
+	}
+
+	cr = newTestCrawler(t)
+	if _, err := cr.Run(context.Background(), Query{Locations: []string{"Berlin"}, Engines: "altavista"}); err == nil {
+		t.Error("Run() with an unknown engine succeeded, want error")
+	}
+	if _, err := cr.Run(context.Background(), Query{Locations: []string{"Berlin"}, Only: []string{"press"}}); err == nil {
+		t.Error("Run() with an unknown category succeeded, want error")
+	}
+	if _, err := cr.Run(context.Background(), Query{Locations: []string{"Berlin"}, Templates: []string{"{company} jobs"}}); err == nil {
+		t.Error("Run() with a template placeholder without values succeeded, want error")
+	}
+	if err := cr.Resume(context.Background(), Query{}, &recordingSink{}); !errors.Is(err, ErrNoInterruptedRun) {
+		t.Errorf("Resume() without an interrupted run = %v, want ErrNoInterruptedRun", err)
+	}
+}
+
+func TestFrontier(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 2
+	cr := newTestCrawler(t, WithConfig(cfg))
+
+	if _, err := cr.lastInterruptedRun(); !errors.Is(err, ErrNoInterruptedRun) {
+		t.Fatalf("lastInterruptedRun() on a new database = %v, want ErrNoInterruptedRun", err)
+	}
+
+	run, err := cr.startRun([]string{"Berlin", "Munich"}, "bing", []string{"{role} jobs {location}"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A run finished after the interrupted one is not resumed
+	finished, err := cr.startRun([]string{"Hamburg"}, "google", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.finishRun(finished); err != nil {
+		t.Fatal(err)
+	}
+
+	// attempts counts the times each URL was started, ending in state
+	for _, entry := range []struct {
+		url      string
+		attempts int
+		state    string
+	}{
+		{"https://acme.com/queued", 0, frontierQueued},
+		{"https://acme.com/done", 1, frontierDone},
+		{"https://acme.com/interrupted", 1, frontierInProgress},
+		{"https://acme.com/failed-once", 1, frontierFailed},
+		{"https://acme.com/failed-twice", 2, frontierFailed},
+		{"https://acme.com/failed-thrice", 3, frontierFailed},
+	} {
+		if err := cr.enqueueURL(run, frontierTarget, frontierEntry{URL: entry.url, Location: "Berlin"}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < entry.attempts; i++ {
+			if err := cr.markURL(run, frontierEntry{URL: entry.url, Location: "Berlin"}, frontierInProgress, nil); err != nil {
+				t.Fatal(err)
+			}
+			if entry.state == frontierFailed {
+				cr.markURL(run, frontierEntry{URL: entry.url, Location: "Berlin"}, frontierFailed, errors.New("503 Service Unavailable"))
+			}
+		}
+		if entry.state == frontierDone {
+			cr.markURL(run, frontierEntry{URL: entry.url, Location: "Berlin"}, frontierDone, nil)
+		}
+	}
+	// Enqueuing a URL again leaves its state alone
+	if err := cr.enqueueURL(run, frontierTarget, frontierEntry{URL: "https://acme.com/done", Location: "Berlin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := cr.lastInterruptedRun()
+	if err != nil {
+		t.Fatalf("lastInterruptedRun() error: %v", err)
+	}
+	want := &crawlRun{ID: run.ID, Locations: run.Locations, Engines: run.Engines, QueryTemplates: run.QueryTemplates, LinkedIn: true, StartedAt: resumed.StartedAt}
+	if !reflect.DeepEqual(resumed, want) || !resumed.StartedAt.Equal(run.StartedAt) {
+		t.Errorf("lastInterruptedRun() = %+v, want %+v", resumed, run)
+	}
+
+	pending, err := cr.pendingURLs(resumed, frontierTarget)
+	if err != nil {
+		t.Fatalf("pendingURLs() error: %v", err)
+	}
+	var got []string
+	for _, entry := range pending {
+		got = append(got, strings.TrimPrefix(entry.URL, "https://acme.com/")+" "+entry.Location)
+	}
+	if want := []string{"queued Berlin", "interrupted Berlin", "failed-once Berlin", "failed-twice Berlin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pendingURLs() = %q, want %q", got, want)
+	}
+
+	var lastError string
+	if err := cr.db.QueryRow(`SELECT last_error FROM frontier WHERE url = ?`, "https://acme.com/failed-once").Scan(&lastError); err != nil || lastError != "503 Service Unavailable" {
+		t.Errorf("last_error = %q, %v; want the failure", lastError, err)
+	}
+
+	if err := cr.finishRun(resumed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cr.lastInterruptedRun(); !errors.Is(err, ErrNoInterruptedRun) {
+		t.Errorf("lastInterruptedRun() after finishing = %v, want ErrNoInterruptedRun", err)
+	}
+}
+
+func TestCrawlerResume(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/careers":
+			w.Write([]byte(`<html><body>Apply at jobs@acme.com</body></html>`))
+		case "/jobs":
+			w.Write([]byte(`<html><body>Questions? hr@acme.com</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.RateLimit = 1
+	cfg.SearchDepth = 1
+	cr := newTestCrawler(t, WithConfig(cfg), WithResolver(staticResolver{"acme.com": {"mx.acme.com."}}))
+	ctx := context.Background()
+
+	// A run interrupted after its searches, with one page crawled, one
+	// waiting and one that failed once
+	run, err := cr.startRun([]string{"Berlin"}, "bing", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	searches, err := cr.identifyTargetPages(ctx, run.Engines, run.LinkedIn, run.Locations, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range searches {
+		cr.enqueueURL(run, frontierSearch, frontierEntry{URL: page.URL, Location: page.Location, Query: page.Query, Engine: page.Engine.Name()})
+		cr.markURL(run, frontierEntry{URL: page.URL, Location: page.Location}, frontierDone, nil)
+	}
+	for _, path := range []string{"/done", "/careers", "/jobs"} {
+		cr.enqueueURL(run, frontierTarget, frontierEntry{URL: server.URL + path, Location: "Berlin"})
+	}
+	cr.markURL(run, frontierEntry{URL: server.URL + "/done", Location: "Berlin"}, frontierDone, nil)
+	cr.markURL(run, frontierEntry{URL: server.URL + "/jobs", Location: "Berlin"}, frontierInProgress, nil)
+	cr.markURL(run, frontierEntry{URL: server.URL + "/jobs", Location: "Berlin"}, frontierFailed, errors.New("timeout"))
+	saved := Result{Emails: []string{"press@acme.com"}, Location: "Berlin", Source: server.URL + "/done", Timestamp: time.Now()}
+	if err := cr.saveResultsToDB(run, []Result{saved}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.saveSkippedURLs(run, []SkippedURL{{URL: server.URL + "/private", Reason: skipDisallowed}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run that finished does not hide the interrupted one
+	later, err := cr.startRun([]string{"Munich"}, "bing", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.finishRun(later); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &recordingSink{}
+	if err := cr.Resume(ctx, Query{}, sink); err != nil {
+		t.Fatalf("Resume() error: %v", err)
+	}
+
+	var emails []string
+	for _, result := range sink.results {
+		emails = append(emails, result.Emails...)
+	}
+	sort.Strings(emails[1:])
+	if want := []string{"press@acme.com", "hr@acme.com", "jobs@acme.com"}; !reflect.DeepEqual(emails, want) {
+		t.Errorf("Resume() wrote %q, want the saved email first, then %q", emails, want[1:])
+	}
+	if !sink.opened || !sink.closed {
+		t.Error("Resume() did not open and close the sink")
+	}
+	if skipped, err := cr.SkippedURLs(0); err != nil || len(skipped) != 1 || skipped[0].RunID != run.ID {
+		t.Errorf("SkippedURLs(0) after Resume() = %v, %v; want the resumed run's", skipped, err)
+	}
+	for _, path := range requested {
+		if path == "/done" {
+			t.Error("Resume() fetched a page the run had already crawled")
+		}
+	}
+	if _, err := cr.lastInterruptedRun(); !errors.Is(err, ErrNoInterruptedRun) {
+		t.Errorf("lastInterruptedRun() after Resume() = %v, want ErrNoInterruptedRun", err)
+	}
+}
+
+func TestCrawlerRunCrawlsSearchResults(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]bool)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.Host+r.URL.Path] = true
+		mu.Unlock()
+		switch r.Host + r.URL.Path {
+		case "www.bing.com/search":
+			// One organic result, a result on the engine's own site and a
+			// navigation link that is not a result
+			if r.URL.Query().Get("first") == "" {
+				w.Write([]byte(`<html><body>
+					<a href="https://globex.test/">Sponsored</a>
+					<ol>
+						<li class="b_algo"><h2><a href="https://acme.test/">Acme</a></h2></li>
+						<li class="b_algo"><h2><a href="https://www.bing.com/jobs">Bing Jobs</a></h2></li>
+					</ol>
+				</body></html>`))
+			}
+		case "acme.test/":
+			w.Write([]byte(`<html><body><a href="/careers">Careers</a></body></html>`))
+		case "acme.test/careers":
+			w.Write([]byte(`<html><body><h2>How to apply</h2><p>Send your CV to jobs [at] acme [dot] test</p></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	serveAllHosts(t, server)
+
+	cfg := DefaultConfig()
+	cfg.RateLimit = 1
+	cfg.SearchDepth = 2
+	cr := newTestCrawler(t, WithConfig(cfg), WithResolver(staticResolver{"acme.test": {"mx.acme.test."}}))
+
+	found, err := cr.Run(context.Background(), Query{Locations: []string{"Berlin"}, Engines: "bing"})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if len(found) != 1 {
+		t.Fatalf("Run() = %d result(s), want 1: %+v", len(found), found)
+	}
+	result := found[0]
+	if want := []string{"jobs@acme.test"}; !reflect.DeepEqual(result.Emails, want) {
+		t.Errorf("Run() found %q, want the deobfuscated %q", result.Emails, want)
+	}
+	if result.Source != "https://acme.test/careers" || result.Location != "Berlin" {
+		t.Errorf("Run() result from %s for %s, want https://acme.test/careers for Berlin", result.Source, result.Location)
+	}
+	if heading := result.Details["jobs@acme.test"].Heading; heading != "How to apply" {
+		t.Errorf("heading = %q, want How to apply", heading)
+	}
+	mu.Lock()
+	for _, page := range []string{"www.bing.com/jobs", "globex.test/"} {
+		if requested[page] {
+			t.Errorf("Run() crawled %s, which is not an organic search result", page)
+		}
+	}
+	mu.Unlock()
+
+	saved, err := cr.Results(ResultFilter{})
+	if err != nil || len(saved) != 1 {
+		t.Errorf("Results() after Run() = %d result(s), %v; want the one found", len(saved), err)
+	}
+}
+
+func TestCrawlerStreamClosesResults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RateLimit = 0
+	cr := newTestCrawler(t, WithConfig(cfg))
+
+	results := make(chan Result)
+	errc := make(chan error, 1)
+	go func() { errc <- cr.Stream(context.Background(), Query{Locations: []string{"Berlin"}}, results) }()
+
+	for range results {
+		t.Error("Stream() with an invalid config sent a result")
+	}
+	if err := <-errc; err == nil || !strings.Contains(err.Error(), "rate_limit_ms") {
+		t.Errorf("Stream() = %v, want a rate_limit_ms error", err)
+	}
+}
+
+func TestCrawlerStream(t *testing.T) {
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host + r.URL.Path {
+		case "www.bing.com/search":
+			if r.URL.Query().Get("first") == "" {
+				w.Write([]byte(`<html><body><ol>
+					<li class="b_algo"><h2><a href="https://acme.test/careers">Acme careers</a></h2></li>
+					<li class="b_algo"><h2><a href="https://globex.test/careers">Globex careers</a></h2></li>
+				</ol></body></html>`))
+			}
+		case "acme.test/careers":
+			w.Write([]byte(`<html><body>Apply at jobs@acme.test</body></html>`))
+		case "globex.test/careers":
+			// Still loading when the crawl is cancelled
+			<-release
+			w.Write([]byte(`<html><body>Apply at jobs@globex.test</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer unblock()
+	serveAllHosts(t, server)
+
+	cfg := DefaultConfig()
+	cfg.RateLimit = 1
+	cfg.SearchDepth = 1
+	cr := newTestCrawler(t, WithConfig(cfg), WithResolver(staticResolver{
+		"acme.test":   {"mx.acme.test."},
+		"globex.test": {"mx.globex.test."},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan Result)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cr.Stream(ctx, Query{Locations: []string{"Berlin"}, Engines: "bing"}, results)
+	}()
+
+	// Acme's result arrives while Globex's page holds up the crawl
+	select {
+	case result := <-results:
+		if want := []string{"jobs@acme.test"}; !reflect.DeepEqual(result.Emails, want) {
+			t.Errorf("Stream() sent %q first, want %q", result.Emails, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stream() sent no result before the crawl ended")
+	}
+
+	cancel()
+	unblock()
+	for result := range results {
+		t.Errorf("Stream() sent %q after it was cancelled", result.Emails)
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Stream() after cancel = %v, want context.Canceled", err)
+	}
+
+	// The cancelled run is resumed with Globex's page still to crawl
+	sink := &recordingSink{}
+	if err := cr.Resume(context.Background(), Query{}, sink); err != nil {
+		t.Fatalf("Resume() error: %v", err)
+	}
+	var emails []string
+	for _, result := range sink.results {
+		emails = append(emails, result.Emails...)
+	}
+	if want := []string{"jobs@acme.test", "jobs@globex.test"}; !reflect.DeepEqual(emails, want) {
+		t.Errorf("Resume() wrote %q, want %q", emails, want)
+	}
+}
+
+func TestParseOutputs(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"json", []string{"json"}, false},
+		{"csv, SQLite,csv,webhook", []string{"csv", "sqlite", "webhook"}, false},
+		{"ndjson,jsonl", []string{"jsonl"}, false},
+		{"csv,xml", nil, true},
+		{" , ", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOutputs(tt.spec)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseOutputs(%q) = %q, %v; want %q, error %v", tt.spec, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// recordingSink remembers what was written to it, failing writes if err
+// is set
+type recordingSink struct {
+	opened, closed bool
+	results        []Result
+	err            error
+}
+
+func (s *recordingSink) Open() error { s.opened = true; return nil }
+func (s *recordingSink) Write(result Result) error {
+	s.results = append(s.results, result)
+	return s.err
+}
+func (s *recordingSink) Close() error { s.closed = true; return s.err }
+
+func TestFanOut(t *testing.T) {
+	good, bad := &recordingSink{}, &recordingSink{err: errors.New("disk full")}
+	sink := FanOut(bad, good)
+
+	if err := sink.Open(); err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	w := &sinkWriter{crawler: newTestCrawler(t), sink: sink}
+	w.write(Result{Emails: []string{"jobs@acme.com", "press@acme.com"}, Source: "https://acme.com/careers"})
+	w.write(Result{Emails: []string{"press@acme.com"}, Source: "https://acme.com/press"})
+	if err := sink.Close(); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Close() = %v, want the failing sink's error", err)
+	}
+
+	if !good.opened || !good.closed || len(good.results) != 2 {
+		t.Errorf("good sink got %+v, want both results", good)
+	}
+	if len(bad.results) != 2 || w.err == nil {
+		t.Errorf("failing sink got %d result(s) and write error %v, want 2 and an error", len(bad.results), w.err)
+	}
+}
+
+func TestOutputsWithoutResults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OutputDir = t.TempDir()
+	var logged strings.Builder
+	cr := newTestCrawler(t, WithConfig(cfg), WithLogger(log.New(&logged, "", 0)))
+
+	sink, err := cr.Outputs("json,csv", groupByLocationName)
+	if err != nil {
+		t.Fatalf("Outputs() error: %v", err)
+	}
+	if err := sink.Open(); err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Close() without results = %v, want nil", err)
+	}
+
+	if files, _ := os.ReadDir(cfg.OutputDir); len(files) != 0 {
+		t.Errorf("Close() without results wrote %d file(s), want none", len(files))
+	}
+	if !strings.Contains(logged.String(), "No results to save as csv") {
+		t.Errorf("log = %q, want a line saying there was nothing to save", logged.String())
+	}
+}
+
+func TestSQLiteSink(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OutputDir = t.TempDir()
+	sink := newTestCrawler(t, WithConfig(cfg)).outputSink("sqlite", groupByLocationName)
+
+	if err := sink.Open(); err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if err := sink.Write(Result{Emails: []string{"jobs@acme.com", "hr@acme.com"}, Location: "Berlin", Source: "https://acme.com/careers"}); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(cfg.OutputDir, "results_*.sqlite"))
+	if len(files) != 1 {
+		t.Fatalf("found %d SQLite output file(s), want 1", len(files))
+	}
+	db, err := sql.Open("sqlite3", files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM emails WHERE location = 'Berlin' AND company_domain = 'acme.com'`).Scan(&count); err != nil || count != 2 {
+		t.Errorf("SQLite output has %d matching row(s), %v; want 2", count, err)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received []Result
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result Result
+		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if result.Source == "https://acme.com/broken" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		received = append(received, result)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.WebhookURL = server.URL
+	sink := newTestCrawler(t, WithConfig(cfg)).outputSink("webhook", groupByLocationName)
+	if err := sink.Open(); err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if err := sink.Write(Result{Emails: []string{"jobs@acme.com"}, Source: "https://acme.com/careers"}); err != nil {
+		t.Errorf("Write() error: %v", err)
+	}
+	if err := sink.Write(Result{Emails: []string{"jobs@acme.com"}, Source: "https://acme.com/broken"}); err == nil {
+		t.Error("Write() to a failing webhook succeeded, want error")
+	}
+	if len(received) != 1 || received[0].Source != "https://acme.com/careers" {
+		t.Errorf("webhook received %+v, want the careers result", received)
+	}
+
+	cfg.WebhookURL = ""
+	if err := newTestCrawler(t, WithConfig(cfg)).outputSink("webhook", groupByLocationName).Open(); err == nil {
+		t.Error("Open() without webhook_url succeeded, want error")
+	}
+}
+
+func TestProfileSinkNotificationsBestEffort(t *testing.T) {
+	// Telegram is not configured, so the notification fails
+	cfg := DefaultConfig()
+	cfg.OutputDir = t.TempDir()
+	cr := newTestCrawler(t, WithConfig(cfg))
+
+	sink, err := cr.profileSink(SearchProfile{Name: "berlin", OutputFormat: "csv", Notify: []string{"telegram"}})
+	if err != nil {
+		t.Fatalf("profileSink() error: %v", err)
+	}
+	if err := sink.Open(); err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if err := sink.Write(Result{Emails: []string{"jobs@acme.com"}, Location: "Berlin", Source: "https://acme.com/careers"}); err != nil {
+		t.Errorf("Write() error: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Close() = %v, want the failed notification only logged", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(cfg.OutputDir, "results_*.csv")); len(files) != 1 {
+		t.Errorf("found %d CSV output file(s), want 1", len(files))
+	}
+}
+
+func TestJSONLSinkAppends(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OutputDir = t.TempDir()
+	cr := newTestCrawler(t, WithConfig(cfg))
+	found := time.Date(2025, 3, 19, 17, 52, 21, 0, time.UTC)
+
+	for _, source := range []string{"https://acme.com/careers", "https://acme.com/jobs"} {
+		sink := cr.outputSink("jsonl", groupByLocationName)
+		if err := sink.Open(); err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		result := Result{
+			Emails:    []string{"jobs@acme.com", "hr@acme.com"},
+			Location:  "Berlin",
+			Source:    source,
+			Timestamp: found,
+			Details:   map[string]EmailDetail{"jobs@acme.com": {Snippet: "Write to jobs@acme.com"}},
+		}
+		if err := sink.Write(result); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close() error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.OutputDir, jsonlFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want one per email of both runs:\n%s", len(lines), data)
+	}
+	var first emailSighting
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	want := emailSighting{Email: "jobs@acme.com", Category: categoryCareers, Verification: first.Verification, Company: "Acme",
+		CompanyDomain: "acme.com", Location: "Berlin", Timestamp: found, Source: "https://acme.com/careers", Snippet: "Write to jobs@acme.com"}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("first line = %+v, want %+v", first, want)
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	used := make(map[string]bool)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Berlin", "Berlin"},
+		{"berlin", "berlin (2)"},
+		{"Remote/Hybrid: EU [DE]", "Remote Hybrid  EU  DE"},
+		{"'quoted'", "quoted"},
+		{"", "Results"},
+		{"Frankfurt am Main, Hesse, Germany, Europe", "Frankfurt am Main, Hesse, Germa"},
+		{"Frankfurt am Main, Hesse, Germany", "Frankfurt am Main, Hesse, G (2)"},
+	}
+
+	for _, tt := range tests {
+		if got := xlsxSheetName(tt.name, used); got != tt.want {
+			t.Errorf("xlsxSheetName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSaveXLSX(t *testing.T) {
+	found := time.Date(2025, 3, 19, 18, 0, 0, 0, time.UTC)
+	batch := []Result{
+		{Emails: []string{"jobs@acme.com", "hr@acme.com"}, Location: "Berlin", Source: "https://acme.com/careers?a=1&b=2", Timestamp: found},
+		{Emails: []string{"jobs@startup.io"}, Location: "Remote/EU", Source: "https://startup.io/jobs", Timestamp: found},
+	}
+	filename := filepath.Join(t.TempDir(), "results.xlsx")
+	if err := writeResults(filename, "xlsx", groupByLocationName, batch); err != nil {
+		t.Fatalf("writeResults() error: %v", err)
+	}
+
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatalf("output is not a zip file: %v", err)
+	}
+	defer archive.Close()
+	parts := make(map[string]string)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(data)
+
+		// Every part must be well-formed XML
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not valid XML: %v", f.Name, err)
+			}
+		}
+	}
+
+	for _, want := range []string{`<sheet name="Berlin"`, `<sheet name="Remote EU"`, `'Berlin'!$A$1:$M$3`} {
+		if !strings.Contains(parts["xl/workbook.xml"], want) {
+			t.Errorf("workbook.xml does not contain %s", want)
+		}
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{`<autoFilter ref="A1:M3"/>`, `<c r="H2" s="2"><v>45735.75</v></c>`, `<hyperlink ref="I3" r:id="rId2"/>`} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet1.xml does not contain %s", want)
+		}
+	}
+	if !strings.Contains(parts["xl/worksheets/_rels/sheet1.xml.rels"], `Target="https://acme.com/careers?a=1&amp;b=2" TargetMode="External"`) {
+		t.Error("sheet1.xml.rels does not link the source URL")
+	}
+}
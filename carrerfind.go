@@ -1,17 +1,16 @@
-package main
+package careerfind
 
 import (
 	"context"
-	"database/sql"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
-	"flag"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -21,440 +20,320 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/gocolly/colly"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/robfig/cron/v3"
 	"golang.org/x/net/proxy"
 )
 
 // Version information
 const VERSION = "2.0.0"
 
-// Configuration structure with expanded fields
-type Config struct {
-	TelegramBotToken string `json:"telegram_bot_token"`
-	TelegramChatID   string `json:"telegram_chat_id"`
-	ProxyAddress     string `json:"proxy_address"`
-	RequestTimeout   int    `json:"request_timeout_seconds"`
-	RateLimit        int    `json:"rate_limit_ms"`
-	UserAgent        string `json:"user_agent"`
-}
-
 // Results structure with metadata
 type Result struct {
-	Emails    []string  `json:"emails"`
+	Emails []string `json:"emails"`
+	// Location is the place searched for and Query the search that
+	// surfaced Source.
 	Location  string    `json:"location"`
+	Query     string    `json:"query"`
 	Timestamp time.Time `json:"timestamp"`
 	Source    string    `json:"source"`
+	// PageTitle is the source page's title and JobTitle the job it
+	// advertises, if it looks like a job posting.
+	PageTitle string `json:"page_title,omitempty"`
+	JobTitle  string `json:"job_title,omitempty"`
+	// Details holds what was learned about each email on this page
+	Details map[string]EmailDetail `json:"details,omitempty"`
 }
 
-// Global variables
-var (
-	config  Config
-	results []Result
-	mu      sync.Mutex
-	logger  *log.Logger
-	db      *sql.DB
-)
-
-func init() {
-	// Initialize logger
-	logFile, err := os.OpenFile("careerfind.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
-	}
-	logger = log.New(logFile, "", log.LstdFlags)
-
-	// Load configuration with environment variables priority
-	loadConfig()
-
-	// Initialize database
-	initDB()
+// EmailDetail describes one email found on a result's page
+type EmailDetail struct {
+	Category string `json:"category"`
+	// Snippet is the text around the email and Heading the nearest
+	// heading above it.
+	Snippet string `json:"snippet,omitempty"`
+	Heading string `json:"heading,omitempty"`
+	// Verification is the email's status from its syntax and DNS records;
+	// see emailVerifier
+	Verification string `json:"verification,omitempty"`
+	// Company is the registrable domain of the company the email belongs
+	// to; see emailCompany
+	Company string `json:"company,omitempty"`
 }
 
-func loadConfig() {
-	// Try environment variables first
-	config = Config{
-		TelegramBotToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
-		TelegramChatID:   os.Getenv("TELEGRAM_CHAT_ID"),
-		ProxyAddress:     os.Getenv("PROXY_ADDRESS"),
-		RequestTimeout:   getEnvInt("REQUEST_TIMEOUT", 30),
-		RateLimit:        getEnvInt("RATE_LIMIT_MS", 1000),
-		UserAgent:        os.Getenv("USER_AGENT"),
+// Category returns the category of an email of the result, classifying it
+// from its address alone if it was not recorded.
+func (r Result) Category(email string) string {
+	if detail, ok := r.Details[email]; ok && detail.Category != "" {
+		return detail.Category
 	}
+	return classifyEmail(email, "")
+}
 
-	// Fall back to config file if env vars not set
-	if config.TelegramBotToken == "" || config.TelegramChatID == "" {
-		if err := loadConfigFromFile(); err != nil {
-			logger.Printf("Warning: Could not load config file: %v", err)
-		}
+// Company returns the company domain of an email of the result
+func (r Result) Company(email string) string {
+	if detail, ok := r.Details[email]; ok && detail.Company != "" {
+		return detail.Company
 	}
+	return emailCompany(email, r.Source)
+}
 
-	// Set default user agent if not specified
-	if config.UserAgent == "" {
-		config.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+// Verification returns the verification status of an email of the result
+func (r Result) Verification(email string) string {
+	if detail, ok := r.Details[email]; ok && detail.Verification != "" {
+		return detail.Verification
 	}
+	return verifyUnknown
 }
 
-func getEnvInt(key string, defaultVal int) int {
-	if val := os.Getenv(key); val != "" {
-		if parsed, err := strconv.Atoi(val); err == nil {
-			return parsed
+// Validate reports whether a result is complete enough to be saved
+func (r Result) Validate() error {
+	if len(r.Emails) == 0 {
+		return errors.New("result has no emails")
+	}
+	for _, email := range r.Emails {
+		if !isValidEmail(email) {
+			return fmt.Errorf("invalid email %q", email)
 		}
 	}
-	return defaultVal
-}
-
-func loadConfigFromFile() error {
-	configFile, err := os.Open("config.json")
-	if err != nil {
-		return fmt.Errorf("error opening config file: %w", err)
+	if r.Source == "" {
+		return errors.New("result has no source")
 	}
-	defer configFile.Close()
-
-	decoder := json.NewDecoder(configFile)
-	if err := decoder.Decode(&config); err != nil {
-		return fmt.Errorf("error decoding config file: %w", err)
+	if r.Timestamp.IsZero() {
+		return errors.New("result has no timestamp")
 	}
 	return nil
 }
 
-func initDB() {
-	var err error
-	db, err = sql.Open("sqlite3", "./careerfind.db")
-	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
-	}
-
-	createTableSQL := `CREATE TABLE IF NOT EXISTS results (
-		"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,		
-		"emails" TEXT,
-		"location" TEXT,
-		"timestamp" DATETIME,
-		"source" TEXT
-	);`
+// searchPage is a search engine results page to be mined for target sites.
+// A nil Engine marks a page that is crawled directly, such as LinkedIn jobs.
+type searchPage struct {
+	Engine   SearchEngine
+	Location string
+	Query    string
+	URL      string
+}
 
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
-}
-
-func main() {
-	// Command-line arguments with improved descriptions
-	location := flag.String("L", "", "Filter by location (city/country)")
-	proxyEnabled := flag.Bool("p", false, "Enable proxy support (requires proxy_address in config)")
-	searchEngines := flag.String("b", "all", "Search engines: google,bing,duckduckgo (comma-separated)")
-	linkedinMode := flag.Bool("l", false, "Enable LinkedIn mode for job post emails")
-	outputFormat := flag.String("o", "json", "Output format: csv,json,txt")
-	notificationMethod := flag.String("m", "telegram", "Notification method: telegram,none")
-	verbose := flag.Bool("v", false, "Enable verbose logging")
-	automation := flag.Bool("a", false, "Enable daily automation")
-	version := flag.Bool("version", false, "Show version information")
-	flag.Parse()
-
-	if *version {
-		fmt.Printf("CareerFind v%s\n", VERSION)
-		return
-	}
-
-	// Set logger output based on verbose flag
-	if *verbose {
-		log.Printf("Starting CareerFind with location: %s", *location)
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Validate configuration
-	if err := validateConfig(); err != nil {
-		log.Printf("Configuration error: %v", err)
-		os.Exit(1)
-	}
-
-	// Update the identifyTargetPages function to include more search variations
-func identifyTargetPages(ctx context.Context, searchEngines string, linkedinMode bool, location string, proxyEnabled bool) ([]string, error) {
-    if location == "" {
-        return nil, errors.New("location cannot be empty")
-    }
-
-    var pages []string
-    engines := strings.Split(strings.ToLower(searchEngines), ",")
-
-    // Handle "all" option
-    if searchEngines == "all" {
-        engines = []string{"google", "bing", "duckduckgo"}
-    }
-
-    // Load search parameters from config
-    searchQueries := []string{
-        fmt.Sprintf("email careers %s", location),
-        fmt.Sprintf("contact us jobs %s", location),
-        fmt.Sprintf("careers@company %s", location),
-        fmt.Sprintf("hr@company %s", location),
-        fmt.Sprintf("recruitment %s email", location),
-        fmt.Sprintf("apply jobs %s contact", location),
-    }
-
-    for _, engine := range engines {
-        for _, query := range searchQueries {
-            encoded := url.QueryEscape(query)
-            var searchURL string
-
-            switch engine {
-            case "google":
-                searchURL = fmt.Sprintf("https://www.google.com/search?q=%s&num=100", encoded)
-            case "bing":
-                searchURL = fmt.Sprintf("https://www.bing.com/search?q=%s&count=100", encoded)
-            case "duckduckgo":
-                searchURL = fmt.Sprintf("https://duckduckgo.com/?q=%s", encoded)
-            default:
-                continue
-            }
-
-            if searchURL != "" {
-                pages = append(pages, searchURL)
-            }
-        }
-    }
-
-    if linkedinMode {
-        queries := []string{
-            fmt.Sprintf("jobs %s", location),
-            fmt.Sprintf("careers %s", location),
-            fmt.Sprintf("hiring %s", location),
-        }
-        for _, q := range queries {
-            linkedinURL := fmt.Sprintf("https://www.linkedin.com/jobs/search?keywords=%s", url.QueryEscape(q))
-            pages = append(pages, linkedinURL)
-        }
-    }
-
-    if len(pages) == 0 {
-        return nil, errors.New("no valid search engines specified")
-    }
-
-    logger.Printf("Generated %d search URLs", len(pages))
-    return pages, nil
-}
-
-// Update the processPage function with better email extraction
-func processPage(ctx context.Context, page string, proxyEnabled bool, verbose bool) error {
-    c := colly.NewCollector(
-        colly.MaxDepth(config.SearchDepth),
-        colly.Async(true),
-        colly.UserAgent(config.UserAgent),
-        colly.AllowURLRevisit(),
-    )
-
-    // Set timeout
-    c.SetRequestTimeout(time.Duration(config.RequestTimeout) * time.Second)
-
-    if proxyEnabled && config.ProxyAddress != "" {
-        if err := setupProxy(c); err != nil {
-            return fmt.Errorf("proxy setup failed: %w", err)
-        }
-        if verbose {
-            logger.Printf("Using proxy: %s", config.ProxyAddress)
-        }
-    }
-
-    // Add retry on error
-    c.OnError(func(r *colly.Response, err error) {
-        if verbose {
-            logger.Printf("Error on %s: %v", r.Request.URL, err)
-        }
-        retries := 0
-        for retries < config.MaxRetries {
-            if verbose {
-                logger.Printf("Retrying %s (attempt %d/%d)", r.Request.URL, retries+1, config.MaxRetries)
-            }
-            time.Sleep(time.Duration(1<<uint(retries)) * time.Second) // Exponential backoff
-            err := c.Visit(r.Request.URL.String())
-            if err == nil {
-                break
-            }
-            retries++
-        }
-    })
-
-    emailRegex := regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
-    
-    c.OnHTML("*", func(e *colly.HTMLElement) {
-        // Extract from text content
-        if emails := extractEmailsFromText(e.Text, emailRegex); len(emails) > 0 {
-            storeResults(emails, page, e.Request.URL.String(), verbose)
-        }
-
-        // Extract from links
-        e.ForEach("a[href^='mailto:']", func(_ int, el *colly.HTMLElement) {
-            if href := el.Attr("href"); strings.HasPrefix(href, "mailto:") {
-                email := strings.TrimPrefix(href, "mailto:")
-                email = strings.Split(email, "?")[0] // Remove any parameters
-                if isValidEmail(email) {
-                    storeResults([]string{email}, page, e.Request.URL.String(), verbose)
-                }
-            }
-        })
-    })
-
-    return c.Visit(page)
-}
-
-// Add helper function to store results
-func storeResults(emails []string, page string, source string, verbose bool) {
-    mu.Lock()
-    defer mu.Unlock()
-
-    // Filter duplicate emails
-    uniqueEmails := make(map[string]bool)
-    var filteredEmails []string
-    
-    for _, email := range emails {
-        if !uniqueEmails[email] {
-            uniqueEmails[email] = true
-            filteredEmails = append(filteredEmails, email)
-        }
-    }
-
-    if len(filteredEmails) > 0 {
-        results = append(results, Result{
-            Emails:    filteredEmails,
-            Location:  page,
-            Timestamp: time.Now().UTC(),
-            Source:    source,
-        })
-        
-        if verbose {
-            logger.Printf("Found %d unique email(s) on %s", len(filteredEmails), source)
-            for _, email := range filteredEmails {
-                logger.Printf("- %s", email)
-            }
-        }
-    }
+// crawlTarget is a company page surfaced by a search query
+type crawlTarget struct {
+	URL      string
+	Location string
+	Query    string
 }
 
-	// Extract emails with improved error handling
-	if *verbose {
-		log.Printf("Starting email extraction from pages...")
-	}
-	if err := extractEmails(ctx, pages, *proxyEnabled, *verbose); err != nil {
-		log.Printf("Some errors occurred during email extraction: %v", err)
-	}
-
-	// Save results with error handling
-	if err := saveResults(*outputFormat); err != nil {
-		log.Printf("Failed to save results: %v", err)
-		os.Exit(1)
-	}
+// Bytes of page text kept on each side of an email
+const contextRadius = 150
 
-	// Save results to database
-	if err := saveResultsToDB(); err != nil {
-		log.Printf("Failed to save results to database: %v", err)
-	}
+// Matches addresses in page text once obfuscated forms are rewritten
+var emailRegex = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+
+// Links on target sites worth following to find hiring contacts
+var targetLinkRegex = regexp.MustCompile(`(?i)career|job|vacanc|recruit|hiring|join|contact|team|impressum|imprint|about`)
 
-	// Send notifications if enabled
-	if *notificationMethod == "telegram" {
-		if err := sendTelegramNotification(); err != nil {
-			log.Printf("Failed to send Telegram notification: %v", err)
+// identifyTargetPages builds the search pages for the query templates
+// expanded for each location, using the default templates if none are given.
+func (cr *Crawler) identifyTargetPages(ctx context.Context, searchEngines string, linkedinMode bool, locations []string, templates []string, proxyEnabled bool) ([]searchPage, error) {
+	if len(locations) == 0 {
+		return nil, errors.New("location cannot be empty")
+	}
+	for _, location := range locations {
+		if location == "" {
+			return nil, errors.New("location cannot be empty")
 		}
 	}
 
-	// Setup automation if requested
-	if *automation {
-		scheduleAutomation()
+	engines, err := resolveSearchEngines(searchEngines)
+	if err != nil {
+		return nil, err
 	}
 
-	if *verbose {
-		log.Printf("CareerFind execution completed")
-	}
-}
+	templates = cr.config.queryTemplates(templates)
 
-func validateConfig() error {
-	var errors []string
+	var pages []searchPage
+	for _, location := range locations {
+		queries, err := expandQueryTemplates(templates, cr.config.QueryValues, location)
+		if err != nil {
+			return nil, err
+		}
 
-	if config.RequestTimeout <= 0 {
-		errors = append(errors, "invalid request timeout value")
+		for _, searchQuery := range queries {
+			for _, engine := range engines {
+				for page := 0; page < engine.MaxPages(); page++ {
+					pages = append(pages, searchPage{
+						Engine:   engine,
+						Location: location,
+						Query:    searchQuery,
+						URL:      engine.SearchURL(searchQuery, page),
+					})
+				}
+			}
+		}
+
+		if linkedinMode {
+			pages = append(pages, searchPage{
+				Location: location,
+				Query:    location,
+				URL:      "https://www.linkedin.com/jobs/search?keywords=" + url.QueryEscape(location),
+			})
+		}
 	}
 
-	if config.RateLimit <= 0 {
-		errors = append(errors, "invalid rate limit value")
+	if len(pages) == 0 {
+		return nil, errors.New("no valid search engines specified")
 	}
 
-	if config.UserAgent == "" {
-		errors = append(errors, "user agent cannot be empty")
+	return pages, nil
+}
+
+// extractEmails crawls the run's pending search and target pages, saving
+// what each page turns up as it completes and then passing its results to
+// emit. emit is called from several goroutines at once.
+func (cr *Crawler) extractEmails(ctx context.Context, run *crawlRun, pages []searchPage, proxyEnabled bool, verbose bool, emit func(Result)) error {
+	// Queue the search pages; pages without an engine are crawled directly.
+	// On resume these are already in the frontier and are ignored.
+	for _, page := range pages {
+		if page.Engine == nil {
+			if err := cr.queueTarget(run, crawlTarget{URL: page.URL, Location: page.Location, Query: page.Query}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := cr.enqueueURL(run, frontierSearch, frontierEntry{URL: page.URL, Location: page.Location, Query: page.Query, Engine: page.Engine.Name()}); err != nil {
+			return err
+		}
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("configuration validation failed: %s", strings.Join(errors, ", "))
+	// Stage 1: collect organic result links from the search engines
+	searches, err := cr.pendingURLs(run, frontierSearch)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
+	errorList := cr.forEachRateLimited(ctx, len(searches), func(i int) error {
+		entry := searches[i]
+		engine, ok := lookupSearchEngine(entry.Engine)
+		if !ok {
+			err := fmt.Errorf("search engine %q is not registered", entry.Engine)
+			cr.markURL(run, entry, frontierFailed, err)
+			return fmt.Errorf("search %s: %w", entry.URL, err)
+		}
 
-func identifyTargetPages(ctx context.Context, searchEngines string, linkedinMode bool, location string, proxyEnabled bool) ([]string, error) {
-	if location == "" {
-		return nil, errors.New("location cannot be empty")
-	}
+		if err := cr.markURL(run, entry, frontierInProgress, nil); err != nil {
+			return err
+		}
+		found, err := cr.collectTargets(searchPage{Engine: engine, Location: entry.Location, Query: entry.Query, URL: entry.URL}, proxyEnabled, verbose)
+		if err != nil {
+			cr.markURL(run, entry, frontierFailed, err)
+			return fmt.Errorf("search %s: %w", entry.URL, err)
+		}
+
+		for _, target := range found {
+			if err := cr.queueTarget(run, target); err != nil {
+				return err
+			}
+		}
+		return cr.markURL(run, entry, frontierDone, nil)
+	})
 
-	var pages []string
-	engines := strings.Split(strings.ToLower(searchEngines), ",")
+	// Stage 2: crawl the target sites for emails, saving as each completes
+	targets, err := cr.pendingURLs(run, frontierTarget)
+	if err != nil {
+		return err
+	}
 
-	// Handle "all" option
-	if searchEngines == "all" {
-		engines = []string{"google", "bing", "duckduckgo"}
+	if verbose {
+		cr.logger.Printf("Run %d has %d target pages to crawl", run.ID, len(targets))
 	}
 
-	for _, engine := range engines {
-		searchQuery := url.QueryEscape("email careers " + location)
-		var searchURL string
+	errorList = append(errorList, cr.forEachRateLimited(ctx, len(targets), func(i int) error {
+		entry := targets[i]
+		if err := cr.markURL(run, entry, frontierInProgress, nil); err != nil {
+			return err
+		}
 
-		switch engine {
-		case "google":
-			searchURL = "https://www.google.com/search?q=" + searchQuery
-		case "bing":
-			searchURL = "https://www.bing.com/search?q=" + searchQuery
-		case "duckduckgo":
-			searchURL = "https://duckduckgo.com/?q=" + searchQuery
-		default:
-			continue
+		findings, err := cr.processPage(ctx, crawlTarget{URL: entry.URL, Location: entry.Location, Query: entry.Query}, proxyEnabled, verbose)
+		if err != nil {
+			cr.markURL(run, entry, frontierFailed, err)
+			return fmt.Errorf("page %s: %w", entry.URL, err)
+		}
+		// Leave interrupted pages in progress so a resume crawls them again
+		if ctx.Err() != nil {
+			return nil
 		}
 
-		if searchURL != "" {
-			pages = append(pages, searchURL)
+		// Results are saved before they are emitted, so a resumed run has
+		// everything a consumer of an interrupted one may have missed
+		if err := cr.saveResultsToDB(run, findings.Results); err != nil {
+			return err
 		}
-	}
+		if err := cr.saveJobPostingsToDB(run, findings.JobPostings); err != nil {
+			return err
+		}
+		if err := cr.saveSkippedURLs(run, findings.Skipped); err != nil {
+			return err
+		}
+		for _, result := range findings.Results {
+			if verbose {
+				cr.logger.Printf("Found %d unique email(s) on %s", len(result.Emails), result.Source)
+			}
+			emit(result)
+		}
+		if verbose && len(findings.JobPostings) > 0 {
+			cr.logger.Printf("Found %d job posting(s) on %s", len(findings.JobPostings), entry.URL)
+		}
+		return cr.markURL(run, entry, frontierDone, nil)
+	})...)
 
-	if linkedinMode {
-		linkedinURL := "https://www.linkedin.com/jobs/search?keywords=" + url.QueryEscape(location)
-		pages = append(pages, linkedinURL)
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	if len(pages) == 0 {
-		return nil, errors.New("no valid search engines specified")
+	if len(errorList) > 0 {
+		return fmt.Errorf("multiple errors occurred: %s", strings.Join(errorList, "; "))
 	}
 
-	return pages, nil
+	return nil
+}
+
+// queueTarget adds a target site to the run's frontier, or records it as
+// skipped if allowed_domains or denied_domains exclude it. Its robots.txt
+// is checked when it is crawled.
+func (cr *Crawler) queueTarget(run *crawlRun, target crawlTarget) error {
+	if !cr.config.crawlAllowed(target.URL) {
+		return cr.saveSkippedURLs(run, []SkippedURL{{URL: target.URL, Reason: skipDomainFilter}})
+	}
+	return cr.enqueueURL(run, frontierTarget, frontierEntry{URL: target.URL, Location: target.Location, Query: target.Query})
 }
 
-func extractEmails(ctx context.Context, pages []string, proxyEnabled bool, verbose bool) error {
+// forEachRateLimited runs fn for each index in its own goroutine, starting
+// one every RateLimit milliseconds with at most concurrent_requests running,
+// and returns the messages of all errors.
+func (cr *Crawler) forEachRateLimited(ctx context.Context, n int, fn func(i int) error) []string {
 	var wg sync.WaitGroup
-	errs := make(chan error, len(pages))
+	errs := make(chan error, n)
+
+	running := make(chan struct{}, cr.config.maxConcurrentRequests())
 
 	// Create a ticker for rate limiting instead of time.Tick
-	ticker := time.NewTicker(time.Duration(config.RateLimit) * time.Millisecond)
+	ticker := time.NewTicker(time.Duration(cr.config.RateLimit) * time.Millisecond)
 	defer ticker.Stop()
 
-	for _, page := range pages {
+launch:
+	for i := 0; i < n; i++ {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			break launch
 		case <-ticker.C:
+			select {
+			case <-ctx.Done():
+				break launch
+			case running <- struct{}{}:
+			}
 			wg.Add(1)
-			go func(page string) {
+			go func(i int) {
 				defer wg.Done()
-				if err := processPage(ctx, page, proxyEnabled, verbose); err != nil {
-					errs <- fmt.Errorf("page %s: %w", page, err)
+				defer func() { <-running }()
+				if err := fn(i); err != nil {
+					errs <- err
 				}
-			}(page)
+			}(i)
 		}
 	}
 
@@ -468,59 +347,42 @@ func extractEmails(ctx context.Context, pages []string, proxyEnabled bool, verbo
 		errorList = append(errorList, err.Error())
 	}
 
-	if len(errorList) > 0 {
-		return fmt.Errorf("multiple errors occurred: %s", strings.Join(errorList, "; "))
-	}
-
-	return nil
+	return errorList
 }
 
-func processPage(ctx context.Context, page string, proxyEnabled bool, verbose bool) error {
-	c := colly.NewCollector(
-		colly.MaxDepth(2),
-		colly.Async(true),
-		colly.UserAgent(config.UserAgent),
-	)
+// newCollector builds a collector with the shared timeout, proxy, header and
+// logging setup used for both search engines and target sites.
+func (cr *Crawler) newCollector(proxyEnabled bool, verbose bool, options ...func(*colly.Collector)) (*colly.Collector, error) {
+	options = append([]func(*colly.Collector){colly.UserAgent(cr.config.UserAgent)}, options...)
+	c := colly.NewCollector(options...)
 
 	// Set timeout
-	c.SetRequestTimeout(time.Duration(config.RequestTimeout) * time.Second)
+	c.SetRequestTimeout(time.Duration(cr.config.RequestTimeout) * time.Second)
 
-	if proxyEnabled && config.ProxyAddress != "" {
-		if err := setupProxy(c); err != nil {
-			return fmt.Errorf("proxy setup failed: %w", err)
+	// Requests from every collector share the concurrent_requests slots and
+	// host limits
+	var transport http.RoundTripper = http.DefaultTransport
+	if proxyEnabled && cr.config.ProxyAddress != "" {
+		proxyTransport, err := cr.newProxyTransport()
+		if err != nil {
+			return nil, fmt.Errorf("proxy setup failed: %w", err)
 		}
+		transport = proxyTransport
 	}
-
-	emailRegex := regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+	slots, hosts := cr.requestLimits()
+	c.WithTransport(&limitedTransport{base: transport, slots: slots, hosts: hosts})
 
 	// Add error handling for responses
 	c.OnError(func(r *colly.Response, err error) {
 		if verbose {
-			logger.Printf("Error scraping %s: %v", r.Request.URL, err)
+			cr.logger.Printf("Error scraping %s: %v", r.Request.URL, err)
 		}
 	})
 
 	// Add response handling to check status
 	c.OnResponse(func(r *colly.Response) {
 		if verbose {
-			logger.Printf("Visited %s (status: %d)", r.Request.URL, r.StatusCode)
-		}
-	})
-
-	c.OnHTML("*", func(e *colly.HTMLElement) {
-		if emails := extractEmailsFromText(e.Text, emailRegex); len(emails) > 0 {
-			mu.Lock()
-			results = append(results, Result{
-				Emails:    emails,
-				Location:  page,
-				Timestamp: time.Now(),
-				Source:    e.Request.URL.String(),
-			})
-			mu.Unlock()
-
-			if verbose {
-				logger.Printf("Found emails on %s: %v", page, emails)
-			}
+			cr.logger.Printf("Visited %s (status: %d)", r.Request.URL, r.StatusCode)
 		}
 	})
 
@@ -529,31 +391,222 @@ func processPage(ctx context.Context, page string, proxyEnabled bool, verbose bo
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
 		if verbose {
-			logger.Printf("Visiting %s", r.URL)
+			cr.logger.Printf("Visiting %s", r.URL)
+		}
+	})
+
+	return c, nil
+}
+
+// collectTargets fetches a search results page and returns the organic
+// result links the engine finds on it.
+func (cr *Crawler) collectTargets(page searchPage, proxyEnabled bool, verbose bool) ([]crawlTarget, error) {
+	c, err := cr.newCollector(proxyEnabled, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []crawlTarget
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		for _, link := range page.Engine.ResultLinks(e) {
+			targets = append(targets, crawlTarget{URL: link, Location: page.Location, Query: page.Query})
+		}
+	})
+
+	if err := c.Visit(page.URL); err != nil {
+		return nil, fmt.Errorf("failed to visit search page: %w", err)
+	}
+
+	if verbose {
+		cr.logger.Printf("%s returned %d result links for %q", page.Engine.Name(), len(targets), page.Query)
+	}
+	return targets, nil
+}
+
+// pageFindings is what crawling one target site turned up
+type pageFindings struct {
+	Results     []Result
+	JobPostings []JobPosting
+	// Skipped are the URLs robots.txt kept the crawler from
+	Skipped []SkippedURL
+}
+
+// processPage crawls a target site from its landing page, following links
+// to careers, jobs and contact pages on the same host. URLs disallowed by
+// the host's robots.txt are skipped and requests are spaced by its
+// Crawl-delay.
+func (cr *Crawler) processPage(ctx context.Context, target crawlTarget, proxyEnabled bool, verbose bool) (pageFindings, error) {
+	landing, err := url.Parse(target.URL)
+	if err != nil {
+		return pageFindings{}, fmt.Errorf("invalid target URL: %w", err)
+	}
+
+	c, err := cr.newCollector(proxyEnabled, verbose,
+		colly.MaxDepth(cr.config.SearchDepth),
+		colly.Async(true),
+	)
+	if err != nil {
+		return pageFindings{}, err
+	}
+
+	// Stay on the target's site, which may span hosts such as acme.com,
+	// www.acme.com and jobs.acme.com
+	site := registrableDomain(landing.Hostname())
+	onSite := func(u *url.URL) bool {
+		return registrableDomain(u.Hostname()) == site
+	}
+	c.RedirectHandler = func(req *http.Request, via []*http.Request) error {
+		if !onSite(req.URL) {
+			return fmt.Errorf("not following redirect to %s outside %s", req.URL.Host, site)
 		}
+		if len(via) >= 10 {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+
+	var (
+		findings pageFindings
+		foundMu  sync.Mutex
+	)
+
+	c.OnRequest(func(r *colly.Request) {
+		if ok, reason := cr.robots.check(r.URL, proxyEnabled); !ok {
+			foundMu.Lock()
+			findings.Skipped = append(findings.Skipped, SkippedURL{URL: r.URL.String(), Reason: reason})
+			foundMu.Unlock()
+			r.Abort()
+			return
+		}
+		cr.robots.wait(ctx, r.URL, proxyEnabled)
 	})
 
-	err := c.Visit(page)
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		source := e.Request.URL.String()
+
+		// Structured job postings, whose contacts count as found emails
+		pagePostings := extractJobPostings(e, source)
+		if len(pagePostings) > 0 {
+			foundMu.Lock()
+			findings.JobPostings = append(findings.JobPostings, pagePostings...)
+			foundMu.Unlock()
+		}
+
+		// Obfuscated addresses are rewritten before matching
+		text := deobfuscateText(e.Text)
+		emails := extractEmailsFromText(text, emailRegex)
+
+		// Extract from links, including Cloudflare-protected ones
+		e.ForEach("a[href]", func(_ int, el *colly.HTMLElement) {
+			email := mailtoAddress(el.Attr("href"))
+			if email == "" {
+				email = cloudflareLinkEmail(el.Attr("href"))
+			}
+			if isValidEmail(email) {
+				emails = append(emails, email)
+			}
+		})
+		e.ForEach("[data-cfemail]", func(_ int, el *colly.HTMLElement) {
+			if email := decodeCloudflareEmail(el.Attr("data-cfemail")); isValidEmail(email) {
+				emails = append(emails, email)
+			}
+		})
+		for _, posting := range pagePostings {
+			if posting.ContactEmail != "" {
+				emails = append(emails, posting.ContactEmail)
+			}
+		}
+		if len(emails) == 0 {
+			return
+		}
+
+		// Record what the page says around each address
+		page := extractPageContext(e, text, emails)
+		if page.JobTitle == "" && len(pagePostings) == 1 {
+			page.JobTitle = pagePostings[0].Title
+		}
+
+		if result, ok := newResult(emails, page, target, source); ok {
+			cr.verifier.verifyResult(ctx, &result)
+			foundMu.Lock()
+			findings.Results = append(findings.Results, result)
+			foundMu.Unlock()
+		}
+	})
+
+	// Follow links that are likely to lead to hiring contacts
+	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		if ctx.Err() != nil {
+			return
+		}
+		href := e.Attr("href")
+		link, err := url.Parse(e.Request.AbsoluteURL(href))
+		if err != nil || !onSite(link) {
+			return
+		}
+		if targetLinkRegex.MatchString(href) || targetLinkRegex.MatchString(e.Text) {
+			e.Request.Visit(link.String())
+		}
+	})
+
+	err = c.Visit(target.URL)
 	if err != nil {
-		return fmt.Errorf("failed to visit page %s: %w", page, err)
+		return pageFindings{}, fmt.Errorf("failed to visit page %s: %w", target.URL, err)
 	}
 
 	// Wait for all requests to finish
 	c.Wait()
-	return nil
+	return findings, nil
+}
+
+// newResult builds a result from the unique emails found on source while
+// crawling target, classifying each by the text around it on the page. It
+// reports false when there are no emails.
+func newResult(emails []string, page pageContext, target crawlTarget, source string) (Result, bool) {
+	// Filter duplicate emails
+	uniqueEmails := make(map[string]bool)
+	var filteredEmails []string
+	details := make(map[string]EmailDetail)
+
+	for _, email := range emails {
+		if !uniqueEmails[email] {
+			uniqueEmails[email] = true
+			filteredEmails = append(filteredEmails, email)
+			details[email] = EmailDetail{
+				Category: classifyEmail(email, page.Snippets[email]),
+				Snippet:  page.Snippets[email],
+				Heading:  page.Headings[email],
+				Company:  emailCompany(email, source),
+			}
+		}
+	}
+
+	if len(filteredEmails) == 0 {
+		return Result{}, false
+	}
+
+	return Result{
+		Emails:    filteredEmails,
+		Location:  target.Location,
+		Query:     target.Query,
+		Timestamp: time.Now().UTC(),
+		Source:    source,
+		PageTitle: page.Title,
+		JobTitle:  page.JobTitle,
+		Details:   details,
+	}, true
 }
 
-func setupProxy(c *colly.Collector) error {
-	dialer, err := proxy.SOCKS5("tcp", config.ProxyAddress, nil, proxy.Direct)
+// newProxyTransport returns a transport dialing through the SOCKS5 proxy
+func (cr *Crawler) newProxyTransport() (*http.Transport, error) {
+	dialer, err := proxy.SOCKS5("tcp", cr.config.ProxyAddress, nil, proxy.Direct)
 	if err != nil {
-		return fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+		return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
 	}
 
-	c.WithTransport(&http.Transport{
+	return &http.Transport{
 		DialContext: dialer.(proxy.ContextDialer).DialContext,
-	})
-
-	return nil
+	}, nil
 }
 
 func extractEmailsFromText(text string, regex *regexp.Regexp) []string {
@@ -571,39 +624,97 @@ func extractEmailsFromText(text string, regex *regexp.Regexp) []string {
 	return result
 }
 
+// isValidEmail reports whether email is a well-formed address rather than
+// a file name that happens to contain an @
 func isValidEmail(email string) bool {
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	return emailRegex.MatchString(email)
+	return checkEmailSyntax(email) == nil && !isAssetName(email)
+}
+
+// saveResults writes batch to a new file in output_dir
+func (cr *Crawler) saveResults(format string, group string, batch []Result) error {
+	filename, err := cr.resultsFilename(format)
+	if err != nil {
+		return err
+	}
+	return writeResults(filename, format, group, batch)
 }
 
-func saveResults(format string) error {
-	if len(results) == 0 {
-		return errors.New("no results to save")
+// resultsFilename returns a new timestamped file name in output_dir,
+// creating the directory if needed
+func (cr *Crawler) resultsFilename(format string) (string, error) {
+	if err := os.MkdirAll(cr.config.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
+	return filepath.Join(cr.config.OutputDir, fmt.Sprintf("results_%s.%s", time.Now().Format("20060102_150405"), format)), nil
+}
 
-	filename := fmt.Sprintf("results_%s.%s", time.Now().Format("20060102_150405"), format)
+// Export writes results to a file in format (csv, json, jsonl, txt or
+// xlsx), grouped by "location" or "company". An empty filename means a new
+// timestamped file in output_dir. It returns the name of the file written.
+func (cr *Crawler) Export(results []Result, format string, group string, filename string) (string, error) {
+	if err := validateGrouping(group); err != nil {
+		return "", err
+	}
+	if filename == "" {
+		var err error
+		if filename, err = cr.resultsFilename(format); err != nil {
+			return "", err
+		}
+	}
+	if err := writeResults(filename, format, group, results); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// writeResults writes batch to filename in the given output format, with
+// results grouped by location or company
+func writeResults(filename string, format string, group string, batch []Result) error {
+	// JSON Lines has one line per email, so it is only sorted
+	if format == "jsonl" {
+		return saveJSONL(filename, SortByLocation(batch))
+	}
+	if format == "xlsx" {
+		return saveXLSX(filename, group, batch)
+	}
+
+	if group == groupByCompanyName {
+		companies := GroupByCompany(batch)
+		switch format {
+		case "json":
+			return saveJSON(filename, companies)
+		case "csv":
+			return saveCSV(filename, companyResults(companies))
+		case "txt":
+			return saveCompanyTXT(filename, companies)
+		default:
+			return fmt.Errorf("unsupported format: %s", format)
+		}
+	}
+
+	batch = SortByLocation(batch)
 
 	switch format {
 	case "json":
-		return saveJSON(filename)
+		return saveJSON(filename, batch)
 	case "csv":
-		return saveCSV(filename)
+		return saveCSV(filename, batch)
 	case "txt":
-		return saveTXT(filename)
+		return saveTXT(filename, batch)
 	default:
 		return fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
-func saveJSON(filename string) error {
-	data, err := json.MarshalIndent(results, "", "  ")
+func saveJSON(filename string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal results: %w", err)
 	}
 	return os.WriteFile(filename, data, 0644)
 }
 
-func saveCSV(filename string) error {
+func saveCSV(filename string, batch []Result) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
@@ -614,19 +725,14 @@ func saveCSV(filename string) error {
 	defer writer.Flush()
 
 	// Write header
-	if err := writer.Write([]string{"Email", "Location", "Timestamp", "Source"}); err != nil {
+	if err := writer.Write(emailColumns); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
 	// Write data
-	for _, result := range results {
+	for _, result := range batch {
 		for _, email := range result.Emails {
-			if err := writer.Write([]string{
-				email,
-				result.Location,
-				result.Timestamp.Format(time.RFC3339),
-				result.Source,
-			}); err != nil {
+			if err := writer.Write(emailRow(result, email)); err != nil {
 				return fmt.Errorf("failed to write CSV row: %w", err)
 			}
 		}
@@ -635,62 +741,113 @@ func saveCSV(filename string) error {
 	return nil
 }
 
-func saveTXT(filename string) error {
+// emailColumns name the fields of emailRow, one row per email
+var emailColumns = []string{"Company", "Company Domain", "Email", "Category", "Verification", "Location", "Query", "Timestamp", "Source", "Page Title", "Job Title", "Heading", "Snippet"}
+
+// emailRow returns the fields of one email of result for tabular outputs
+func emailRow(result Result, email string) []string {
+	return []string{
+		companyName(result.Company(email)),
+		result.Company(email),
+		email,
+		result.Category(email),
+		result.Verification(email),
+		result.Location,
+		result.Query,
+		result.Timestamp.Format(time.RFC3339),
+		result.Source,
+		result.PageTitle,
+		result.JobTitle,
+		result.Details[email].Heading,
+		result.Details[email].Snippet,
+	}
+}
+
+func saveTXT(filename string, batch []Result) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
 	defer file.Close()
 
-	for _, result := range results {
-		fmt.Fprintf(file, "Location: %s\n", result.Location)
-		fmt.Fprintf(file, "Timestamp: %s\n", result.Timestamp.Format(time.RFC3339))
-		fmt.Fprintf(file, "Source: %s\n", result.Source)
-		for _, email := range result.Emails {
-			fmt.Fprintf(file, "Email: %s\n", email)
+	for _, group := range groupByLocation(batch) {
+		fmt.Fprintf(file, "=== Location: %s ===\n", group.Location)
+		for _, result := range group.Results {
+			fmt.Fprintf(file, "Query: %s\n", result.Query)
+			writeTXTResult(file, result)
 		}
-		fmt.Fprintln(file, "---")
 	}
 
 	return nil
 }
 
-func saveResultsToDB() error {
-	if len(results) == 0 {
-		return errors.New("no results to save")
+// saveCompanyTXT writes results as saveTXT does, grouped by company
+func saveCompanyTXT(filename string, companies []Company) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
 	}
+	defer file.Close()
 
-	for _, result := range results {
-		emails := strings.Join(result.Emails, ",")
-		_, err := db.Exec("INSERT INTO results (emails, location, timestamp, source) VALUES (?, ?, ?, ?)",
-			emails, result.Location, result.Timestamp, result.Source)
-		if err != nil {
-			return fmt.Errorf("failed to insert result into database: %w", err)
+	for _, company := range companies {
+		fmt.Fprintf(file, "=== Company: %s (%s) ===\n", company.Name, company.Domain)
+		fmt.Fprintf(file, "Emails: %s\n", strings.Join(company.Emails, ", "))
+		for _, result := range company.Results {
+			fmt.Fprintf(file, "Location: %s\n", result.Location)
+			fmt.Fprintf(file, "Query: %s\n", result.Query)
+			writeTXTResult(file, result)
 		}
 	}
 
 	return nil
 }
 
-func sendTelegramNotification() error {
-	if config.TelegramBotToken == "" || config.TelegramChatID == "" {
+// writeTXTResult writes the page and emails of a result in the TXT format
+func writeTXTResult(w io.Writer, result Result) {
+	fmt.Fprintf(w, "Timestamp: %s\n", result.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(w, "Source: %s\n", result.Source)
+	if result.PageTitle != "" {
+		fmt.Fprintf(w, "Page Title: %s\n", result.PageTitle)
+	}
+	if result.JobTitle != "" {
+		fmt.Fprintf(w, "Job Title: %s\n", result.JobTitle)
+	}
+	for _, email := range result.Emails {
+		fmt.Fprintf(w, "Email: %s (%s, %s)\n", email, result.Category(email), result.Verification(email))
+		if detail := result.Details[email]; detail.Heading != "" {
+			fmt.Fprintf(w, "  Heading: %s\n", detail.Heading)
+		}
+		if detail := result.Details[email]; detail.Snippet != "" {
+			fmt.Fprintf(w, "  Context: %s\n", detail.Snippet)
+		}
+	}
+	fmt.Fprintln(w, "---")
+}
+
+// sendTelegramNotification sends batch to chatID, or to the configured chat
+// when chatID is empty.
+func (cr *Crawler) sendTelegramNotification(chatID string, batch []Result) error {
+	if chatID == "" {
+		chatID = cr.config.TelegramChatID
+	}
+	if cr.config.TelegramBotToken == "" || chatID == "" {
 		return errors.New("Telegram configuration is missing")
 	}
 
-	bot, err := tgbotapi.NewBotAPI(config.TelegramBotToken)
+	bot, err := tgbotapi.NewBotAPI(cr.config.TelegramBotToken)
 	if err != nil {
 		return fmt.Errorf("failed to create Telegram bot: %w", err)
 	}
 
-	message := formatTelegramMessage()
+	message := formatTelegramMessage(batch)
 
 	// Convert chat ID from string to int64
-	chatID, err := strconv.ParseInt(config.TelegramChatID, 10, 64)
+	id, err := strconv.ParseInt(chatID, 10, 64)
 	if err != nil {
 		return fmt.Errorf("invalid Telegram chat ID: %w", err)
 	}
 
-	msg := tgbotapi.NewMessage(chatID, message)
+	msg := tgbotapi.NewMessage(id, message)
 	_, err = bot.Send(msg)
 	if err != nil {
 		return fmt.Errorf("failed to send Telegram message: %w", err)
@@ -699,65 +856,25 @@ func sendTelegramNotification() error {
 	return nil
 }
 
-func formatTelegramMessage() string {
+func formatTelegramMessage(batch []Result) string {
 	var sb strings.Builder
 	sb.WriteString("📧 CareerFind Results\n\n")
 
-	for _, result := range results {
-		sb.WriteString(fmt.Sprintf("📍 Location: %s\n", result.Location))
-		sb.WriteString(fmt.Sprintf("🕒 Time: %s\n", result.Timestamp.Format("2006-01-02 15:04:05")))
-		sb.WriteString("📧 Emails:\n")
-		for _, email := range result.Emails {
-			sb.WriteString(fmt.Sprintf("- %s\n", email))
+	for _, group := range groupByLocation(batch) {
+		sb.WriteString(fmt.Sprintf("📍 Location: %s (%d page(s))\n\n", group.Location, len(group.Results)))
+		for _, result := range group.Results {
+			sb.WriteString(fmt.Sprintf("🕒 Time: %s\n", result.Timestamp.Format("2006-01-02 15:04:05")))
+			if result.JobTitle != "" {
+				sb.WriteString(fmt.Sprintf("💼 Job: %s\n", result.JobTitle))
+			}
+			sb.WriteString("📧 Emails:\n")
+			for _, email := range result.Emails {
+				sb.WriteString(fmt.Sprintf("- %s (%s)\n", email, result.Category(email)))
+			}
+			sb.WriteString("🔗 Source: " + result.Source + "\n")
+			sb.WriteString("-------------------\n")
 		}
-		sb.WriteString("🔗 Source: " + result.Source + "\n")
-		sb.WriteString("-------------------\n")
 	}
 
 	return sb.String()
 }
-
-func scheduleAutomation() {
-	c := cron.New()
-	_, err := c.AddFunc("@daily", func() {
-		ctx := context.Background()
-		if err := runAutomatedSearch(ctx); err != nil {
-			logger.Printf("Automated search failed: %v", err)
-		}
-	})
-
-	if err != nil {
-		logger.Printf("Failed to schedule automation: %v", err)
-		return
-	}
-
-	c.Start()
-	logger.Println("Automation scheduled - will run daily at midnight")
-}
-
-func runAutomatedSearch(ctx context.Context) error {
-	// Default automated search parameters
-	location := "worldwide"
-	searchEngines := "google,bing"
-	proxyEnabled := true
-	verbose := true
-
-	pages, err := identifyTargetPages(ctx, searchEngines, false, location, proxyEnabled)
-	if err != nil {
-		return fmt.Errorf("failed to identify target pages: %w", err)
-	}
-
-	if err := extractEmails(ctx, pages, proxyEnabled, verbose); err != nil {
-		return fmt.Errorf("failed to extract emails: %w", err)
-	}
-
-	if err := saveResults("json"); err != nil {
-		return fmt.Errorf("failed to save results: %w", err)
-	}
-
-	if err := sendTelegramNotification(); err != nil {
-		return fmt.Errorf("failed to send notification: %w", err)
-	}
-
-	return nil
-}
@@ -0,0 +1,155 @@
+package careerfind
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Email categories assigned by classifyEmail
+const (
+	categoryRecruiting = "recruiting"
+	categoryCareers    = "careers"
+	categoryContact    = "contact"
+	categorySupport    = "support"
+	categoryNoReply    = "noreply"
+	categoryPersonal   = "personal"
+	categoryOther      = "other"
+)
+
+// EmailCategories lists every category in the order they are reported
+var EmailCategories = []string{
+	categoryRecruiting,
+	categoryCareers,
+	categoryContact,
+	categorySupport,
+	categoryNoReply,
+	categoryPersonal,
+	categoryOther,
+}
+
+// categoryAliases are accepted by --only in place of a category name
+var categoryAliases = map[string]string{
+	"hr":      categoryRecruiting,
+	"jobs":    categoryCareers,
+	"general": categoryContact,
+}
+
+// localPartWords matches a word of an email local part, delimited by the
+// start or end, punctuation or digits, so "hr" matches hr@ and hr.team@ but
+// not christine@.
+func localPartWords(words ...string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[._+\-0-9])(` + strings.Join(words, "|") + `)([._+\-0-9]|$)`)
+}
+
+// localPartRules are checked in order; the first match decides the category
+var localPartRules = []struct {
+	category string
+	pattern  *regexp.Regexp
+}{
+	{categoryNoReply, regexp.MustCompile(`(?i)no-?reply|do-?not-?reply|mailer-daemon|postmaster|bounces?([._+\-0-9]|$)|notifications?([._+\-0-9]|$)`)},
+	{categoryRecruiting, localPartWords("hr", "rh", "humanresources", "human-resources", "people", "peopleops", "talents?", "talent-?acquisition", "recruit\\w*", "hiring", "staffing", "applications?", "apply", "bewerbung\\w*", "personal", "resumes?", "cv")},
+	{categoryCareers, localPartWords("careers?", "jobs?", "karriere", "vacanc\\w*", "employment", "joinus", "join-?us", "join", "work", "workwithus", "opportunit\\w*", "stellen\\w*")},
+	{categorySupport, localPartWords("support", "help", "helpdesk", "service", "customer-?service", "customercare", "abuse", "security", "privacy", "gdpr", "dpo", "legal", "billing", "webmaster", "admin", "it", "tech")},
+	{categoryContact, localPartWords("info", "contact", "kontakt", "hello", "hi", "office", "mail", "enquir\\w*", "inquir\\w*", "general", "team", "press", "media", "marketing", "sales", "business")},
+}
+
+// Personal addresses look like first.last@ or are at free mail providers
+var (
+	personalLocalPart = regexp.MustCompile(`^[a-zA-Z]{2,}[._-][a-zA-Z]{2,}$`)
+	freeMailDomains   = map[string]bool{
+		"gmail.com": true, "googlemail.com": true, "yahoo.com": true, "hotmail.com": true,
+		"outlook.com": true, "live.com": true, "icloud.com": true, "me.com": true, "aol.com": true,
+		"gmx.de": true, "gmx.net": true, "web.de": true, "proton.me": true, "protonmail.com": true,
+		"yandex.ru": true, "mail.ru": true,
+	}
+)
+
+// Words in the text around an address that mark it as a hiring contact
+var (
+	recruitingContext = regexp.MustCompile(`(?i)recruit|hiring manager|human resources|\bHR\b|talent|your application|applications? (to|should)|send (us )?your (cv|resume|résumé)|apply|bewerbung`)
+	careersContext    = regexp.MustCompile(`(?i)career|\bjobs?\b|vacanc|open (positions|roles)|job openings|karriere`)
+)
+
+// classifyEmail assigns a category to address from its local part and
+// domain, refined by the page text around it. Generic, personal and
+// unrecognized addresses next to hiring language count as recruiting or
+// careers contacts; noreply and support addresses are never promoted.
+func classifyEmail(address string, context string) string {
+	local, domain, _ := strings.Cut(address, "@")
+
+	category := categoryOther
+	for _, rule := range localPartRules {
+		if rule.pattern.MatchString(local) {
+			category = rule.category
+			break
+		}
+	}
+	if category == categoryOther && (freeMailDomains[strings.ToLower(domain)] || personalLocalPart.MatchString(local)) {
+		category = categoryPersonal
+	}
+
+	switch category {
+	case categoryContact, categoryPersonal, categoryOther:
+		if recruitingContext.MatchString(context) {
+			return categoryRecruiting
+		}
+		if careersContext.MatchString(context) {
+			return categoryCareers
+		}
+	}
+	return category
+}
+
+// ParseCategories turns a comma-separated list of categories, such as a
+// --only value, into category names. Aliases like "hr" are accepted. An
+// empty value selects every category.
+func ParseCategories(spec string) ([]string, error) {
+	var categories []string
+	var unknown []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if alias, ok := categoryAliases[name]; ok {
+			name = alias
+		}
+		if !containsString(EmailCategories, name) {
+			unknown = append(unknown, name)
+			continue
+		}
+		if !containsString(categories, name) {
+			categories = append(categories, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown email categor(ies): %s (available: %s)",
+			strings.Join(unknown, ","), strings.Join(EmailCategories, ","))
+	}
+	return categories, nil
+}
+
+// filterCategories keeps only the emails of results in one of categories,
+// dropping results left without emails. No categories keeps everything.
+func filterCategories(batch []Result, categories []string) []Result {
+	if len(categories) == 0 {
+		return batch
+	}
+
+	var kept []Result
+	for _, result := range batch {
+		var emails []string
+		for _, email := range result.Emails {
+			if containsString(categories, result.Category(email)) {
+				emails = append(emails, email)
+			}
+		}
+		if len(emails) > 0 {
+			result.Emails = emails
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
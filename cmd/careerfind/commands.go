@@ -0,0 +1,490 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harry7u/careerfind"
+)
+
+// Ways of grouping results in output files
+var resultGroupings = []string{"location", "company"}
+
+// addFilterFlags registers the flags shared by commands that read saved
+// results and returns a function building the filter once flags are parsed.
+func addFilterFlags(flags *flag.FlagSet) func() (careerfind.ResultFilter, error) {
+	since := flags.String("since", "", "Only results seen since a duration (7d, 12h) or date (2006-01-02)")
+	domain := flags.String("domain", "", "Only emails at this domain or its subdomains")
+	company := flags.String("company", "", "Only emails of the company with this domain")
+	location := flags.String("location", "", "Only results for this searched location")
+	only := flags.String("only", "", fmt.Sprintf("Only emails in these categories: %s (comma-separated)", strings.Join(careerfind.EmailCategories, ",")))
+	runID := flags.Int64("run", 0, "Only results from this crawl run")
+
+	return func() (careerfind.ResultFilter, error) {
+		filter := careerfind.ResultFilter{Domain: *domain, Company: *company, Location: *location, RunID: *runID}
+		categories, err := careerfind.ParseCategories(*only)
+		if err != nil {
+			return filter, usageError{err}
+		}
+		filter.Categories = categories
+		if *since != "" {
+			t, err := parseSince(*since, time.Now())
+			if err != nil {
+				return filter, usageError{err}
+			}
+			filter.Since = t
+		}
+		return filter, nil
+	}
+}
+
+// parseSince turns a --since value into a point in time. It accepts a day
+// count such as 7d, any time.ParseDuration value, or an RFC 3339 or
+// YYYY-MM-DD date.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.HasSuffix(value, "d") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q: want a duration like 7d or 12h, or a date", value)
+}
+
+// runCommand performs a one-off crawl: `careerfind run`
+func (a *app) runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	var locations stringList
+	flags.Var(&locations, "L", "Location (city/country) to search; repeat for several, or @file for one per line")
+	proxyEnabled := flags.Bool("p", false, "Enable proxy support (requires proxy_address in config)")
+	searchEngines := flags.String("b", "all", fmt.Sprintf("Search engines: %s (comma-separated) or all", strings.Join(careerfind.SearchEngines(), ",")))
+	linkedinMode := flags.Bool("l", false, "Enable LinkedIn mode for job post emails")
+	var templates stringList
+	flags.Var(&templates, "q", "Query template such as \"{role} jobs {location}\"; repeat for several (default from config)")
+	outputFormat := flags.String("o", "json", fmt.Sprintf("Outputs: %s (comma-separated)", strings.Join(careerfind.OutputFormats, ",")))
+	group := flags.String("group", "location", "Group output by location or company")
+	notificationMethod := flags.String("m", "telegram", "Notification method: telegram, telegram:<chat id> or none")
+	only := flags.String("only", strings.Join(a.cfg.OnlyCategories, ","), fmt.Sprintf("Only output emails in these categories: %s (comma-separated)", strings.Join(careerfind.EmailCategories, ",")))
+	verbose := flags.Bool("v", false, "Enable verbose logging")
+	resume := flags.Bool("resume", false, "Resume the last interrupted run")
+	depth := flags.Int("depth", a.cfg.SearchDepth, "Links deep to crawl on each target site, counting the landing page")
+	retries := flags.Int("retries", a.cfg.MaxRetries, "Times a failed URL is retried on resume")
+	concurrency := flags.Int("concurrency", a.cfg.ConcurrentRequests, "Maximum requests in flight across all sites")
+	allow := flags.String("allow-domains", strings.Join(a.cfg.AllowedDomains, ","), "Only crawl sites at these domains (comma-separated)")
+	deny := flags.String("deny-domains", strings.Join(a.cfg.DeniedDomains, ","), "Never crawl sites at these domains (comma-separated)")
+	flags.Usage = commandUsage(flags, "run [flags]", "Search for career pages and extract hiring emails.")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	// Flags default to the configured values, so they take priority
+	a.cfg.SearchDepth, a.cfg.MaxRetries, a.cfg.ConcurrentRequests = *depth, *retries, *concurrency
+	a.cfg.AllowedDomains, a.cfg.DeniedDomains = careerfind.ParseDomainList(*allow), careerfind.ParseDomainList(*deny)
+
+	if *verbose {
+		log.Printf("Starting CareerFind with location(s): %s", strings.Join(locations, "; "))
+	}
+
+	// Stop crawling on SIGINT/SIGTERM; unfinished pages stay resumable
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	// Validate configuration
+	if err := a.validateConfig(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	categories, err := careerfind.ParseCategories(*only)
+	if err != nil {
+		return usageError{err}
+	}
+	if err := a.cfg.CheckQueryTemplates(templates); err != nil {
+		return usageError{err}
+	}
+	q := careerfind.Query{
+		Engines:   *searchEngines,
+		Templates: templates,
+		LinkedIn:  *linkedinMode,
+		Proxy:     *proxyEnabled,
+		Verbose:   *verbose,
+		Only:      categories,
+	}
+	if !*resume {
+		if q.Locations, err = careerfind.LoadLocations(locations); err != nil {
+			return usageError{err}
+		}
+	}
+
+	if !containsString(resultGroupings, *group) {
+		return usageError{fmt.Errorf("invalid -group %q: want location or company", *group)}
+	}
+
+	cr, err := a.crawler()
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+
+	// Everything found is in the database; outputs only get the wanted
+	// emails. Notifications are best effort.
+	outputs, err := cr.Outputs(*outputFormat, *group)
+	if err != nil {
+		return usageError{err}
+	}
+	notification, err := cr.Notification(*notificationMethod)
+	if err != nil {
+		return usageError{err}
+	}
+	sink := outputs
+	if notification != nil {
+		sink = careerfind.FanOut(outputs, notification)
+	}
+
+	if *verbose {
+		log.Printf("Searching and extracting emails from pages...")
+	}
+	if *resume {
+		// The interrupted run's search parameters replace the flags
+		if err = cr.Resume(ctx, q, sink); err != nil && !errors.Is(err, careerfind.ErrSomePagesFailed) {
+			return fmt.Errorf("failed to resume: %w", err)
+		}
+	} else {
+		err = cr.Crawl(ctx, q, sink)
+	}
+	if errors.Is(err, careerfind.ErrSomePagesFailed) {
+		log.Printf("Some errors occurred during email extraction: %v", err)
+		err = nil
+	}
+	a.printSkipped(cr)
+	if err != nil {
+		return err
+	}
+
+	if *verbose {
+		log.Printf("CareerFind execution completed")
+	}
+	return nil
+}
+
+// printSkipped prints how many URLs the run cr crawled skipped for each
+// reason
+func (a *app) printSkipped(cr *careerfind.Crawler) {
+	skipped, err := cr.SkippedURLs(0)
+	if err != nil {
+		a.logger.Printf("Failed to report skipped URLs: %v", err)
+		return
+	}
+	if len(skipped) == 0 {
+		return
+	}
+
+	var reasons []string
+	counts := make(map[string]int)
+	for _, s := range skipped {
+		if counts[s.Reason] == 0 {
+			reasons = append(reasons, s.Reason)
+		}
+		counts[s.Reason]++
+	}
+	for _, reason := range reasons {
+		fmt.Printf("Skipped %d URL(s): %s\n", counts[reason], reason)
+	}
+	fmt.Printf("See `careerfind skipped -run %d` for the list\n", skipped[0].RunID)
+}
+
+// exportCommand writes saved results to a file: `careerfind export`
+func (a *app) exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "json", "Output format: csv,json,jsonl,txt,xlsx")
+	output := flags.String("out", "", "Output file (default results_<timestamp>.<format> in output_dir)")
+	group := flags.String("group", "location", "Group results by location or company")
+	filter := addFilterFlags(flags)
+	flags.Usage = commandUsage(flags, "export [flags]", "Write results saved in careerfind.db to a file without running a crawl.")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if !containsString(resultGroupings, *group) {
+		return usageError{fmt.Errorf("invalid --group %q: want location or company", *group)}
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	cr, err := a.crawler()
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+
+	saved, err := cr.Results(f)
+	if err != nil {
+		return err
+	}
+	if len(saved) == 0 {
+		return errors.New("no saved results match")
+	}
+
+	filename, err := cr.Export(saved, *format, *group, *output)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d result(s) to %s\n", len(saved), filename)
+	return nil
+}
+
+// queryCommand prints saved results: `careerfind query`
+func (a *app) queryCommand(args []string) error {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	byCompany := flags.Bool("by-company", false, "Group emails by company, starting each line with the company domain")
+	filter := addFilterFlags(flags)
+	flags.Usage = commandUsage(flags, "query [flags]", "Print emails saved in careerfind.db, one per line with the location searched\nand the page they were found on.")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	cr, err := a.crawler()
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+
+	saved, err := cr.Results(f)
+	if err != nil {
+		return err
+	}
+
+	if *byCompany {
+		for _, company := range careerfind.GroupByCompany(saved) {
+			for _, result := range company.Results {
+				for _, email := range result.Emails {
+					fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\t%s\n", company.Domain, email, result.Category(email), result.Verification(email), result.Location, result.Timestamp.Format("2006-01-02"), result.Source)
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, result := range careerfind.SortByLocation(saved) {
+		for _, email := range result.Emails {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", email, result.Category(email), result.Verification(email), result.Location, result.Timestamp.Format("2006-01-02"), result.Source)
+		}
+	}
+	return nil
+}
+
+// jobsCommand prints saved job postings: `careerfind jobs`
+func (a *app) jobsCommand(args []string) error {
+	flags := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	since := flags.String("since", "", "Only postings seen since a duration (7d, 12h) or date (2006-01-02)")
+	company := flags.String("company", "", "Only postings of the company with this domain")
+	runID := flags.Int64("run", 0, "Only postings from this crawl run")
+	flags.Usage = commandUsage(flags, "jobs [flags]", "Print schema.org job postings saved in careerfind.db, newest first, one per\nline with the company, location, contact and page.")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	filter := careerfind.JobPostingFilter{Company: *company, RunID: *runID}
+	if *since != "" {
+		t, err := parseSince(*since, time.Now())
+		if err != nil {
+			return usageError{err}
+		}
+		filter.Since = t
+	}
+
+	cr, err := a.crawler()
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+
+	postings, err := cr.JobPostings(filter)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range postings {
+		link := p.URL
+		if link == "" {
+			link = p.Source
+		}
+		organization := p.Organization
+		if organization == "" {
+			organization = p.Company
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", p.DatePosted, p.Title, organization, p.Location, p.ContactEmail, link)
+	}
+	return nil
+}
+
+// skippedCommand prints the URLs a run did not crawl: `careerfind skipped`
+func (a *app) skippedCommand(args []string) error {
+	flags := flag.NewFlagSet("skipped", flag.ContinueOnError)
+	runID := flags.Int64("run", 0, "Crawl run to report on (default the latest)")
+	flags.Usage = commandUsage(flags, "skipped [flags]", "Print the URLs a crawl run skipped because of robots.txt or the allowed and\ndenied domains, one per line with the reason.")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cr, err := a.crawler()
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+
+	skipped, err := cr.SkippedURLs(*runID)
+	if err != nil {
+		return err
+	}
+	for _, s := range skipped {
+		fmt.Printf("%s\t%s\n", s.URL, s.Reason)
+	}
+	return nil
+}
+
+// scheduleCommand runs the automated search as a long-running daemon until
+// it receives SIGINT or SIGTERM: `careerfind schedule`
+func (a *app) scheduleCommand(args []string) error {
+	flags := flag.NewFlagSet("schedule", flag.ContinueOnError)
+	only := flags.String("profile", "", "Only run these search profiles (comma-separated)")
+	verbose := flags.Bool("v", false, "Log every request of the searches")
+	flags.Usage = commandUsage(flags, "schedule [flags]", "Run each search profile from the config on its cron schedule, or a daily\nworldwide search if none are configured. Runs in the foreground until\ninterrupted; suitable as a systemd service.")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if err := a.validateConfig(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	profiles := a.cfg.SearchProfiles()
+	if *only != "" {
+		var selected []careerfind.SearchProfile
+		for _, name := range strings.Split(*only, ",") {
+			profile, ok := findProfile(profiles, strings.TrimSpace(name))
+			if !ok {
+				return usageError{fmt.Errorf("unknown search profile %q", name)}
+			}
+			selected = append(selected, profile)
+		}
+		profiles = selected
+	}
+
+	cr, err := a.crawler(careerfind.WithVerbose(*verbose))
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	fmt.Printf("Running %d search profile(s); see %s for their searches\n", len(profiles), a.cfg.LogPath)
+	return cr.Schedule(ctx, profiles)
+}
+
+// findProfile returns the profile with the given name
+func findProfile(profiles []careerfind.SearchProfile, name string) (careerfind.SearchProfile, bool) {
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return careerfind.SearchProfile{}, false
+}
+
+// configCommand handles `careerfind config validate`
+func (a *app) configCommand(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return usageError{errors.New("usage: careerfind config validate")}
+	}
+
+	flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	flags.Usage = commandUsage(flags, "config validate", "Check the configuration from the environment and the config file.")
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	if err := a.validateConfig(); err != nil {
+		return err
+	}
+	fmt.Printf("Configuration OK (%s)\n", a.configPath)
+	return nil
+}
+
+// migrateCommand shows or applies the schema migrations: `careerfind
+// migrate status|up`. The database is opened as it is, so status can show
+// what is pending.
+func (a *app) migrateCommand(args []string) error {
+	if len(args) != 1 || (args[0] != "status" && args[0] != "up") {
+		return usageError{errors.New("usage: careerfind migrate status|up")}
+	}
+
+	cr, err := a.crawler(careerfind.WithoutMigrations())
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+	return runMigrateCommand(cr, args[0], os.Stdout)
+}
+
+// runMigrateCommand applies the pending migrations for up, and then prints
+// the schema version and each migration to out
+func runMigrateCommand(cr *careerfind.Crawler, action string, out io.Writer) error {
+	if action == "up" {
+		if err := cr.Migrate(); err != nil {
+			return err
+		}
+	}
+
+	migrations, err := cr.Migrations()
+	if err != nil {
+		return err
+	}
+
+	current := 0
+	for _, m := range migrations {
+		if !m.AppliedAt.IsZero() {
+			current = m.Version
+		}
+	}
+	fmt.Fprintf(out, "Schema version: %d (latest %d)\n", current, migrations[len(migrations)-1].Version)
+	for _, m := range migrations {
+		if m.AppliedAt.IsZero() {
+			fmt.Fprintf(out, "  pending  %-20s  %s\n", "", m.Name)
+		} else {
+			fmt.Fprintf(out, "  applied  %s  %s\n", m.AppliedAt.Format(time.RFC3339), m.Name)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+// Command careerfind searches for career pages and extracts hiring emails.
+// See the careerfind package for using the crawler from Go.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/harry7u/careerfind"
+)
+
+func main() {
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// command is a careerfind subcommand
+type command struct {
+	Name    string
+	Summary string
+	Run     func(a *app, args []string) error
+}
+
+// commands lists the subcommands in the order they appear in the usage text
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "Search for career pages and extract hiring emails", (*app).runCommand},
+		{"export", "Write saved results from careerfind.db to a file", (*app).exportCommand},
+		{"query", "Print saved results from careerfind.db", (*app).queryCommand},
+		{"jobs", "Print job postings saved in careerfind.db", (*app).jobsCommand},
+		{"skipped", "Print the URLs a run skipped because of robots.txt or domain filters", (*app).skippedCommand},
+		{"schedule", "Run the configured search profiles on their schedules", (*app).scheduleCommand},
+		{"config", "Check the configuration", (*app).configCommand},
+		{"migrate", "Show or apply database schema migrations", (*app).migrateCommand},
+		{"version", "Show version information", func(a *app, args []string) error {
+			fmt.Printf("CareerFind v%s\n", careerfind.VERSION)
+			return nil
+		}},
+	}
+}
+
+// runCLI runs the careerfind command with args, the command line without
+// the program name. It parses the global flags, loads the config and
+// dispatches to the subcommand named next. It returns the process exit
+// code.
+func runCLI(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "-help", "--help", "help":
+			printUsage(os.Stdout)
+			return 0
+		case "-version", "--version":
+			args[0] = "version"
+		}
+	}
+
+	global := flag.NewFlagSet("careerfind", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	paths := addGlobalFlags(global)
+	if err := global.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "careerfind: %v\n\n", err)
+		printUsage(os.Stderr)
+		return 2
+	}
+	if global.NArg() == 0 {
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	name, rest := global.Arg(0), global.Args()[1:]
+	for _, cmd := range commands {
+		if cmd.Name != name {
+			continue
+		}
+		a, err := setup(*paths)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return 1
+		}
+		defer a.Close()
+
+		err = cmd.Run(a, rest)
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if err != nil {
+			if errors.Is(err, errUsage) {
+				return 2
+			}
+			var usageErr usageError
+			if errors.As(err, &usageErr) {
+				fmt.Fprintf(os.Stderr, "careerfind %s: %v\n", name, err)
+				return 2
+			}
+			log.Printf("Error: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "careerfind: unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return 2
+}
+
+// globalFlags are the flags given before the command. They take priority
+// over the environment and config file.
+type globalFlags struct {
+	ConfigPath string
+	DataDir    string
+	DBPath     string
+	LogPath    string
+	OutputDir  string
+}
+
+// app is what the commands share: the loaded configuration and the log
+type app struct {
+	cfg        careerfind.Config
+	configPath string
+	// envErrors are the environment variables that could not be parsed
+	envErrors []string
+	// configWarning is why the default config file could not be read. It
+	// is logged once the log is open.
+	configWarning error
+	// logFile and logger are set by crawler
+	logFile *os.File
+	logger  *log.Logger
+}
+
+// setup loads the configuration and resolves the paths it names. Settings
+// are taken from, in increasing priority, the defaults, the config file,
+// environment variables and flags.
+func setup(flags globalFlags) (*app, error) {
+	configPath := flags.ConfigPath
+	if configPath == "" {
+		path, err := defaultConfigPath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate config file; use -config: %w", err)
+		}
+		configPath = path
+	}
+
+	cfg, envErrors, fileErr := careerfind.LoadConfig(configPath)
+	if fileErr != nil && flags.ConfigPath != "" {
+		return nil, fileErr
+	}
+	if err := resolvePaths(&cfg, flags); err != nil {
+		return nil, err
+	}
+	return &app{cfg: cfg, configPath: configPath, envErrors: envErrors, configWarning: fileErr}, nil
+}
+
+// Close closes the log file if crawler opened it
+func (a *app) Close() error {
+	if a.logFile == nil {
+		return nil
+	}
+	return a.logFile.Close()
+}
+
+// crawler returns a Crawler with the app's settings, log and database.
+// Commands call it once their command line has been checked and their
+// flags applied to a.cfg; the data directories and the log are created
+// then, so version, config validate and invalid command lines leave no
+// files behind.
+func (a *app) crawler(options ...careerfind.Option) (*careerfind.Crawler, error) {
+	if err := a.openLog(); err != nil {
+		return nil, err
+	}
+	options = append([]careerfind.Option{
+		careerfind.WithConfig(a.cfg),
+		careerfind.WithLogger(a.logger),
+		careerfind.WithDatabase(a.cfg.DBPath),
+	}, options...)
+	return careerfind.New(options...)
+}
+
+// openLog creates the data directories and opens the log file, once
+func (a *app) openLog() error {
+	if a.logFile != nil {
+		return nil
+	}
+	for _, dir := range []string{a.cfg.DataDir, filepath.Dir(a.cfg.DBPath), filepath.Dir(a.cfg.LogPath), a.cfg.OutputDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	logFile, err := os.OpenFile(a.cfg.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	a.logFile, a.logger = logFile, log.New(logFile, "", log.LstdFlags)
+	if a.configWarning != nil {
+		a.logger.Printf("Warning: Could not load config file: %v", a.configWarning)
+	}
+	return nil
+}
+
+// validateConfig reports the environment variables that could not be
+// parsed together with the problems Config.Validate finds
+func (a *app) validateConfig() error {
+	problems := append(append([]string(nil), a.envErrors...), a.cfg.Problems()...)
+	if len(problems) > 0 {
+		return fmt.Errorf("configuration validation failed:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// addGlobalFlags registers the flags accepted before the command name
+func addGlobalFlags(flags *flag.FlagSet) *globalFlags {
+	paths := &globalFlags{}
+	flags.StringVar(&paths.ConfigPath, "config", "", "Config file (default $XDG_CONFIG_HOME/careerfind/config.json)")
+	flags.StringVar(&paths.DataDir, "data-dir", "", "Directory for the database, log and results (default $XDG_DATA_HOME/careerfind)")
+	flags.StringVar(&paths.DBPath, "db", "", "Database file (default db_path from the config)")
+	flags.StringVar(&paths.LogPath, "log", "", "Log file (default log_path from the config)")
+	flags.StringVar(&paths.OutputDir, "output-dir", "", "Directory for result files (default output_dir from the config)")
+	return paths
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintf(out, "CareerFind v%s\n\nUsage: careerfind [global flags] <command> [flags]\n\nCommands:\n", careerfind.VERSION)
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintln(out, "\nGlobal flags:")
+	global := flag.NewFlagSet("careerfind", flag.ContinueOnError)
+	global.SetOutput(out)
+	addGlobalFlags(global)
+	global.PrintDefaults()
+	fmt.Fprintln(out, "\nRun 'careerfind <command> -h' for the flags of a command.")
+}
+
+// usageError reports an invalid command line; runCLI prints it and exits
+// with status 2.
+type usageError struct{ error }
+
+// errUsage is returned for flag parse errors, which the flag package has
+// already printed together with the command's usage.
+var errUsage = errors.New("invalid command line")
+
+// parseFlags parses args and rejects stray positional arguments
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if flags.NArg() > 0 {
+		return usageError{fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))}
+	}
+	return nil
+}
+
+// commandUsage returns a FlagSet.Usage function with a synopsis and summary
+func commandUsage(flags *flag.FlagSet, synopsis, summary string) func() {
+	return func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: careerfind %s\n\n%s\n", synopsis, summary)
+		fmt.Fprintln(out, "\nFlags:")
+		flags.PrintDefaults()
+	}
+}
+
+// stringList is a flag.Value collecting every use of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// signalContext returns a context cancelled on SIGINT or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harry7u/careerfind"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 3, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"7d", now.AddDate(0, 0, -7), false},
+		{"0d", now, false},
+		{"12h", now.Add(-12 * time.Hour), false},
+		{"90m", now.Add(-90 * time.Minute), false},
+		{"2025-03-01", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2025-03-01T08:00:00Z", time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC), false},
+		{"-3d", time.Time{}, true},
+		{"last week", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSince(tt.value, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSince(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	dataDir := filepath.Join(dataHome, "careerfind")
+	absolute := filepath.Join(t.TempDir(), "logs", "careerfind.log")
+	defaults := careerfind.DefaultConfig()
+
+	tests := []struct {
+		name   string
+		config careerfind.Config
+		flags  globalFlags
+		want   careerfind.Config
+	}{
+		{
+			name:   "defaults",
+			config: careerfind.Config{OutputDir: defaults.OutputDir, DBPath: defaults.DBPath, LogPath: defaults.LogPath},
+			want:   careerfind.Config{DataDir: dataDir, OutputDir: dataDir, DBPath: filepath.Join(dataDir, "careerfind.db"), LogPath: filepath.Join(dataDir, "careerfind.log")},
+		},
+		{
+			name:   "relative and absolute config paths",
+			config: careerfind.Config{OutputDir: "results", DBPath: defaults.DBPath, LogPath: absolute},
+			want:   careerfind.Config{DataDir: dataDir, OutputDir: filepath.Join(dataDir, "results"), DBPath: filepath.Join(dataDir, "careerfind.db"), LogPath: absolute},
+		},
+		{
+			name:   "data dir flag",
+			config: careerfind.Config{DataDir: filepath.Join(dataHome, "ignored"), OutputDir: defaults.OutputDir, DBPath: defaults.DBPath, LogPath: defaults.LogPath},
+			flags:  globalFlags{DataDir: filepath.Join(dataHome, "cron")},
+			want: careerfind.Config{DataDir: filepath.Join(dataHome, "cron"), OutputDir: filepath.Join(dataHome, "cron"),
+				DBPath: filepath.Join(dataHome, "cron", "careerfind.db"), LogPath: filepath.Join(dataHome, "cron", "careerfind.log")},
+		},
+		{
+			name:   "path flags",
+			config: careerfind.Config{OutputDir: defaults.OutputDir, DBPath: defaults.DBPath, LogPath: defaults.LogPath},
+			flags:  globalFlags{DBPath: filepath.Join(dataHome, "other.db")},
+			want:   careerfind.Config{DataDir: dataDir, OutputDir: dataDir, DBPath: filepath.Join(dataHome, "other.db"), LogPath: filepath.Join(dataDir, "careerfind.log")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if err := resolvePaths(&config, tt.flags); err != nil {
+				t.Fatalf("resolvePaths() error: %v", err)
+			}
+			if !reflect.DeepEqual(config, tt.want) {
+				t.Errorf("resolvePaths() set %+v, want %+v", config, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandsWithoutDataLeaveNoFiles(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	for _, args := range [][]string{{"version"}, {"config", "validate"}, {"run", "-no-such-flag"}, {"migrate", "down"}} {
+		runCLI(args)
+		if _, err := os.Stat(filepath.Join(dataHome, "careerfind")); !os.IsNotExist(err) {
+			t.Fatalf("careerfind %s created the data directory", strings.Join(args, " "))
+		}
+	}
+}
+
+func TestRunRejectsTemplatesWithoutValues(t *testing.T) {
+	a := &app{cfg: careerfind.DefaultConfig()}
+	err := a.runCommand([]string{"-L", "Berlin", "-q", "{company} jobs"})
+	var usageErr usageError
+	if !errors.As(err, &usageErr) || !strings.Contains(err.Error(), "{company}") {
+		t.Errorf("runCommand() with -q \"{company} jobs\" = %v, want a usage error naming {company}", err)
+	}
+}
+
+func TestRunMigrateCommand(t *testing.T) {
+	cr, err := careerfind.New(careerfind.WithDatabase(filepath.Join(t.TempDir(), "careerfind.db")), careerfind.WithoutMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cr.Close()
+
+	var out bytes.Buffer
+	if err := runMigrateCommand(cr, "status", &out); err != nil {
+		t.Fatalf("migrate status error = %v", err)
+	}
+	if strings.Contains(out.String(), "applied") || !strings.Contains(out.String(), "pending") {
+		t.Errorf("migrate status on a new database = %q, want only pending migrations", out.String())
+	}
+	if migrations, err := cr.Migrations(); err != nil || !migrations[0].AppliedAt.IsZero() {
+		t.Errorf("migrate status changed the database: Migrations() = %v, %v", migrations, err)
+	}
+
+	out.Reset()
+	if err := runMigrateCommand(cr, "up", &out); err != nil {
+		t.Fatalf("migrate up error = %v", err)
+	}
+	if strings.Contains(out.String(), "pending") {
+		t.Errorf("migrate up left pending migrations: %q", out.String())
+	}
+
+	var usageErr usageError
+	if err := (&app{}).migrateCommand([]string{"down"}); !errors.As(err, &usageErr) {
+		t.Errorf("migrate down = %v, want a usage error", err)
+	}
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	want := filepath.Join(configHome, "careerfind", "config.json")
+
+	// A config.json in the working directory is used until the XDG one exists
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, legacyConfigPath), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	if got, err := defaultConfigPath(); err != nil || got != legacyConfigPath {
+		t.Errorf("defaultConfigPath() = %q, %v; want %q", got, err, legacyConfigPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(want), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(want, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := defaultConfigPath(); err != nil || got != want {
+		t.Errorf("defaultConfigPath() = %q, %v; want %q", got, err, want)
+	}
+
+	// Relative XDG values are ignored
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	t.Setenv("HOME", configHome)
+	if got, err := xdgDir("XDG_CONFIG_HOME", ".config"); err != nil || got != filepath.Join(configHome, ".config", "careerfind") {
+		t.Errorf("xdgDir() with relative $XDG_CONFIG_HOME = %q, %v", got, err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Setenv("MAX_RETRIES", "three")
+	cfg, envErrors, _ := careerfind.LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	cfg.SearchDepth = 0
+
+	a := &app{cfg: cfg, envErrors: envErrors}
+	err := a.validateConfig()
+	if err == nil {
+		t.Fatal("validateConfig() = nil, want errors")
+	}
+	for _, want := range []string{`MAX_RETRIES: "three" is not an integer`, "search_depth: must be at least 1, got 0"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("validateConfig() = %v, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestSetFromFlag(t *testing.T) {
+	path := "/tmp/other.db"
+	setFromFlag(&path, "")
+	if path != "/tmp/other.db" {
+		t.Errorf("setFromFlag() without a flag set %q", path)
+	}
+	setFromFlag(&path, "flag.db")
+	if path != "flag.db" {
+		t.Errorf("setFromFlag() = %q, want the flag value", path)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/harry7u/careerfind"
+)
+
+// appName names the careerfind directories under the XDG base directories
+const appName = "careerfind"
+
+// legacyConfigPath is where config.json was read from before the XDG
+// directories were used; it is still read if the XDG file does not exist
+const legacyConfigPath = "config.json"
+
+// xdgDir returns $<envKey>/careerfind, or fallback/careerfind below the
+// home directory if the variable is unset. Relative values are ignored, as
+// the XDG Base Directory spec requires.
+func xdgDir(envKey string, fallback string) (string, error) {
+	if dir := os.Getenv(envKey); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory for $%s: %w", envKey, err)
+	}
+	return filepath.Join(home, fallback, appName), nil
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/careerfind/config.json, or
+// ./config.json if only that exists
+func defaultConfigPath() (string, error) {
+	dir, err := xdgDir("XDG_CONFIG_HOME", ".config")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "config.json")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(legacyConfigPath); err == nil {
+			return legacyConfigPath, nil
+		}
+	}
+	return path, nil
+}
+
+// defaultDataDir returns $XDG_DATA_HOME/careerfind, where the database, log
+// and result files go unless configured otherwise
+func defaultDataDir() (string, error) {
+	return xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// resolvePath places a relative path from the config file or environment
+// inside dir, so files land in the same place wherever careerfind is
+// started from
+func resolvePath(path string, dir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// resolvePaths sets the data directory and the database, log and output
+// paths of cfg from the flags and the loaded config. The directories are
+// created when a command first uses them.
+func resolvePaths(cfg *careerfind.Config, flags globalFlags) error {
+	setFromFlag(&cfg.DataDir, flags.DataDir)
+	if cfg.DataDir == "" {
+		dir, err := defaultDataDir()
+		if err != nil {
+			return fmt.Errorf("failed to locate data directory; use -data-dir: %w", err)
+		}
+		cfg.DataDir = dir
+	}
+
+	cfg.DBPath = resolvePath(cfg.DBPath, cfg.DataDir)
+	cfg.LogPath = resolvePath(cfg.LogPath, cfg.DataDir)
+	cfg.OutputDir = resolvePath(cfg.OutputDir, cfg.DataDir)
+
+	// Paths on the command line are relative to the working directory
+	setFromFlag(&cfg.DBPath, flags.DBPath)
+	setFromFlag(&cfg.LogPath, flags.LogPath)
+	setFromFlag(&cfg.OutputDir, flags.OutputDir)
+	return nil
+}
+
+// setFromFlag overwrites *field with a flag value if one was given
+func setFromFlag(field *string, val string) {
+	if val != "" {
+		*field = val
+	}
+}
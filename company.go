@@ -0,0 +1,170 @@
+package careerfind
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Company is an organization contacts were found for, identified by the
+// registrable domain of its email addresses, so jobs@acme.com and
+// hr@eu.acme.com both belong to acme.com.
+type Company struct {
+	Domain string   `json:"domain"`
+	Name   string   `json:"name"`
+	Emails []string `json:"emails"`
+	// Results are the pages the company's emails were found on, each with
+	// only the company's emails
+	Results []Result `json:"results"`
+}
+
+// Ways of grouping results in output files
+const (
+	groupByLocationName = "location"
+	groupByCompanyName  = "company"
+)
+
+var resultGroupings = []string{groupByLocationName, groupByCompanyName}
+
+// validateGrouping reports a group other than "location" or "company"
+func validateGrouping(group string) error {
+	if !containsString(resultGroupings, group) {
+		return fmt.Errorf("invalid group %q: want location or company", group)
+	}
+	return nil
+}
+
+// registrableDomain returns the domain a host was registered under, such as
+// acme.co.uk for careers.acme.co.uk. Hosts without one, like IP addresses
+// and bare public suffixes, are returned as they are.
+func registrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// emailCompany returns the company domain of an email found on source.
+// Free mail addresses belong to the site that published them.
+func emailCompany(email string, source string) string {
+	_, domain, _ := strings.Cut(email, "@")
+	domain = strings.ToLower(domain)
+	if freeMailDomains[domain] {
+		if u, err := url.Parse(source); err == nil && u.Hostname() != "" {
+			domain = u.Hostname()
+		}
+	}
+	return registrableDomain(domain)
+}
+
+// companyName makes a display name from a company domain: acme-corp.co.uk
+// becomes "Acme Corp".
+func companyName(domain string) string {
+	label, _, _ := strings.Cut(domain, ".")
+	if strings.HasPrefix(label, "xn--") || net.ParseIP(domain) != nil {
+		return domain
+	}
+	words := strings.FieldsFunc(label, func(r rune) bool { return r == '-' || r == '_' })
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	if len(words) == 0 {
+		return domain
+	}
+	return strings.Join(words, " ")
+}
+
+// GroupByCompany splits results by the company of each email, sorted by
+// company name. A page with emails of several companies is listed under
+// each of them with only that company's emails.
+func GroupByCompany(batch []Result) []Company {
+	var companies []Company
+	index := make(map[string]int)
+	for _, result := range batch {
+		var domains []string
+		emails := make(map[string][]string)
+		for _, email := range result.Emails {
+			domain := result.Company(email)
+			if _, ok := emails[domain]; !ok {
+				domains = append(domains, domain)
+			}
+			emails[domain] = append(emails[domain], email)
+		}
+
+		for _, domain := range domains {
+			i, ok := index[domain]
+			if !ok {
+				i = len(companies)
+				index[domain] = i
+				companies = append(companies, Company{Domain: domain, Name: companyName(domain)})
+			}
+			for _, email := range emails[domain] {
+				if !containsString(companies[i].Emails, email) {
+					companies[i].Emails = append(companies[i].Emails, email)
+				}
+			}
+			part := result
+			part.Emails = emails[domain]
+			part.Details = make(map[string]EmailDetail)
+			for _, email := range part.Emails {
+				if detail, ok := result.Details[email]; ok {
+					part.Details[email] = detail
+				}
+			}
+			companies[i].Results = append(companies[i].Results, part)
+		}
+	}
+
+	sort.SliceStable(companies, func(i, j int) bool {
+		a, b := strings.ToLower(companies[i].Name), strings.ToLower(companies[j].Name)
+		if a != b {
+			return a < b
+		}
+		return companies[i].Domain < companies[j].Domain
+	})
+	return companies
+}
+
+// companyResults returns the results of companies in order, for formats
+// that list one row per email
+func companyResults(companies []Company) []Result {
+	var batch []Result
+	for _, company := range companies {
+		batch = append(batch, company.Results...)
+	}
+	return batch
+}
+
+// filterCompany keeps only the emails of results that belong to the company
+// with domain, dropping results left without emails. Any host of the
+// company names it too. An empty domain keeps everything.
+func filterCompany(batch []Result, domain string) []Result {
+	if domain == "" {
+		return batch
+	}
+	domain = registrableDomain(strings.TrimPrefix(domain, "@"))
+
+	var kept []Result
+	for _, result := range batch {
+		var emails []string
+		for _, email := range result.Emails {
+			if result.Company(email) == domain {
+				emails = append(emails, email)
+			}
+		}
+		if len(emails) > 0 {
+			result.Emails = emails
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,234 @@
+package careerfind
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config holds every setting of a Crawler. Start from DefaultConfig and
+// change what you need.
+type Config struct {
+	TelegramBotToken string `json:"telegram_bot_token"`
+	TelegramChatID   string `json:"telegram_chat_id"`
+	ProxyAddress     string `json:"proxy_address"`
+	RequestTimeout   int    `json:"request_timeout_seconds"`
+	RateLimit        int    `json:"rate_limit_ms"`
+	UserAgent        string `json:"user_agent"`
+	// SearchDepth is how many links deep each target site is crawled,
+	// counting the landing page as 1
+	SearchDepth int `json:"search_depth"`
+	// MaxRetries is how often a failed URL is tried again on resume
+	MaxRetries int `json:"max_retries"`
+	// AllowedDomains, if set, are the only sites crawled for emails, and
+	// DeniedDomains are never crawled. Each entry covers its subdomains.
+	AllowedDomains []string `json:"allowed_domains"`
+	DeniedDomains  []string `json:"denied_domains"`
+	// DataDir holds the database, log and result files of the careerfind
+	// command, which places relative OutputDir, DBPath and LogPath inside
+	// it. A Crawler only uses a database given with WithDatabase.
+	DataDir   string `json:"data_dir"`
+	OutputDir string `json:"output_dir"`
+	DBPath    string `json:"db_path"`
+	LogPath   string `json:"log_path"`
+	// ConcurrentRequests caps the requests in flight across all sites, and
+	// HostLimits throttle each host; see limits.go
+	ConcurrentRequests int         `json:"concurrent_requests"`
+	HostLimits         []HostLimit `json:"host_limits"`
+	// QueryTemplates and QueryValues define the search queries; see
+	// expandQueryTemplates
+	QueryTemplates []string            `json:"query_templates"`
+	QueryValues    map[string][]string `json:"query_values"`
+	// OnlyCategories limits output files and notifications to emails in
+	// these categories; see classifyEmail
+	OnlyCategories []string `json:"only_categories"`
+	// WebhookURL receives each result as JSON with the webhook output
+	WebhookURL string `json:"webhook_url"`
+	// Profiles are the searches run by `careerfind schedule`
+	Profiles []SearchProfile `json:"profiles"`
+}
+
+// Defaults for settings the config file, environment and command line
+// leave unset
+const (
+	defaultRequestTimeout = 30
+	defaultRateLimit      = 1000
+	defaultSearchDepth    = 2
+	defaultMaxRetries     = 2
+	defaultOutputDir      = "."
+	defaultDBPath         = "careerfind.db"
+	defaultLogPath        = "careerfind.log"
+	defaultUserAgent      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+)
+
+// DefaultConfig returns the settings used when nothing else is configured
+func DefaultConfig() Config {
+	return Config{
+		RequestTimeout:     defaultRequestTimeout,
+		RateLimit:          defaultRateLimit,
+		UserAgent:          defaultUserAgent,
+		SearchDepth:        defaultSearchDepth,
+		MaxRetries:         defaultMaxRetries,
+		OutputDir:          defaultOutputDir,
+		DBPath:             defaultDBPath,
+		LogPath:            defaultLogPath,
+		ConcurrentRequests: defaultConcurrentRequests,
+	}
+}
+
+// LoadConfig returns the defaults overridden by the config file at path and
+// then by the environment, along with the environment variables that could
+// not be parsed. A config file that cannot be read is returned as an error;
+// the other sources still apply.
+func LoadConfig(path string) (Config, []string, error) {
+	cfg := DefaultConfig()
+
+	// The config file supplies the search profiles and any settings not
+	// given as environment variables
+	fileErr := loadConfigFromFile(path, &cfg)
+
+	// Environment variables take priority over the config file
+	setFromEnv(&cfg.TelegramBotToken, "TELEGRAM_BOT_TOKEN")
+	setFromEnv(&cfg.TelegramChatID, "TELEGRAM_CHAT_ID")
+	setFromEnv(&cfg.ProxyAddress, "PROXY_ADDRESS")
+	setFromEnv(&cfg.UserAgent, "USER_AGENT")
+	setFromEnv(&cfg.DataDir, "DATA_DIR")
+	setFromEnv(&cfg.OutputDir, "OUTPUT_DIR")
+	setFromEnv(&cfg.DBPath, "DB_PATH")
+	setFromEnv(&cfg.LogPath, "LOG_PATH")
+	setFromEnv(&cfg.WebhookURL, "WEBHOOK_URL")
+
+	var envErrors []string
+	for _, env := range []struct {
+		key   string
+		field *int
+	}{
+		{"REQUEST_TIMEOUT", &cfg.RequestTimeout},
+		{"RATE_LIMIT_MS", &cfg.RateLimit},
+		{"CONCURRENT_REQUESTS", &cfg.ConcurrentRequests},
+		{"SEARCH_DEPTH", &cfg.SearchDepth},
+		{"MAX_RETRIES", &cfg.MaxRetries},
+	} {
+		if err := setIntFromEnv(env.field, env.key); err != nil {
+			envErrors = append(envErrors, err.Error())
+		}
+	}
+	if val := os.Getenv("ALLOWED_DOMAINS"); val != "" {
+		cfg.AllowedDomains = ParseDomainList(val)
+	}
+	if val := os.Getenv("DENIED_DOMAINS"); val != "" {
+		cfg.DeniedDomains = ParseDomainList(val)
+	}
+
+	// Empty strings in the config file mean the default
+	setDefault(&cfg.UserAgent, defaultUserAgent)
+	setDefault(&cfg.OutputDir, defaultOutputDir)
+	setDefault(&cfg.DBPath, defaultDBPath)
+	setDefault(&cfg.LogPath, defaultLogPath)
+	return cfg, envErrors, fileErr
+}
+
+// setFromEnv overwrites *field with the environment variable key if it is set
+func setFromEnv(field *string, key string) {
+	if val := os.Getenv(key); val != "" {
+		*field = val
+	}
+}
+
+func setDefault(field *string, val string) {
+	if *field == "" {
+		*field = val
+	}
+}
+
+// setIntFromEnv overwrites *field with the integer in the environment
+// variable key if it is set
+func setIntFromEnv(field *int, key string) error {
+	val := os.Getenv(key)
+	if val == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(val)
+	if err != nil {
+		return fmt.Errorf("%s: %q is not an integer", key, val)
+	}
+	*field = parsed
+	return nil
+}
+
+func loadConfigFromFile(path string, cfg *Config) error {
+	configFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening config file: %w", err)
+	}
+	defer configFile.Close()
+
+	decoder := json.NewDecoder(configFile)
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("error decoding config file: %w", err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting, each named by its config file key
+func (cfg Config) Validate() error {
+	if problems := cfg.Problems(); len(problems) > 0 {
+		return fmt.Errorf("configuration validation failed:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Problems returns a description of each invalid setting, as reported by
+// Validate
+func (cfg Config) Problems() []string {
+	var problems []string
+
+	if cfg.RequestTimeout <= 0 {
+		problems = append(problems, fmt.Sprintf("request_timeout_seconds: must be positive, got %d", cfg.RequestTimeout))
+	}
+
+	if cfg.RateLimit <= 0 {
+		problems = append(problems, fmt.Sprintf("rate_limit_ms: must be positive, got %d", cfg.RateLimit))
+	}
+
+	if cfg.UserAgent == "" {
+		problems = append(problems, "user_agent: cannot be empty")
+	}
+
+	if cfg.SearchDepth <= 0 {
+		problems = append(problems, fmt.Sprintf("search_depth: must be at least 1, got %d", cfg.SearchDepth))
+	}
+	if cfg.MaxRetries < 0 {
+		problems = append(problems, fmt.Sprintf("max_retries: cannot be negative, got %d", cfg.MaxRetries))
+	}
+
+	if cfg.ConcurrentRequests <= 0 {
+		problems = append(problems, fmt.Sprintf("concurrent_requests: must be positive, got %d", cfg.ConcurrentRequests))
+	}
+	problems = append(problems, validateHostLimits(cfg.HostLimits)...)
+	problems = append(problems, validateDomainLists(cfg.AllowedDomains, cfg.DeniedDomains)...)
+
+	if info, err := os.Stat(cfg.OutputDir); err == nil && !info.IsDir() {
+		problems = append(problems, fmt.Sprintf("output_dir: %s is not a directory", cfg.OutputDir))
+	}
+	for _, p := range []struct{ name, path string }{{"db_path", cfg.DBPath}, {"log_path", cfg.LogPath}} {
+		if info, err := os.Stat(p.path); err == nil && info.IsDir() {
+			problems = append(problems, fmt.Sprintf("%s: %s is a directory", p.name, p.path))
+		}
+	}
+
+	if _, ok := cfg.QueryValues[locationPlaceholder]; ok {
+		problems = append(problems, "query_values: cannot define \"location\"; locations come from -L or the profile")
+	}
+	for _, problem := range validateQueryTemplates(cfg.QueryTemplates, cfg.QueryValues) {
+		problems = append(problems, "query_templates: "+problem)
+	}
+	if _, err := ParseCategories(strings.Join(cfg.OnlyCategories, ",")); err != nil {
+		problems = append(problems, fmt.Sprintf("only_categories: %v", err))
+	}
+	problems = append(problems, validateWebhookURL(cfg.WebhookURL)...)
+	problems = append(problems, validateProfiles(cfg.Profiles, cfg.QueryValues)...)
+	return problems
+}
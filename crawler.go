@@ -0,0 +1,319 @@
+package careerfind
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Crawler searches for career pages and extracts hiring emails. Each
+// Crawler has its own settings, database and logger, so several can be
+// used in one program. Create one with New.
+type Crawler struct {
+	config Config
+	db     *sql.DB
+	// closeDB is set when the Crawler opened db itself
+	closeDB bool
+	logger  *log.Logger
+	// verbose logs every request of every crawl
+	verbose  bool
+	resolver Resolver
+	// skipMigrations leaves the database schema for Migrate
+	skipMigrations bool
+
+	verifier *emailVerifier
+	robots   *robotsCache
+
+	// slots caps the requests in flight across all collectors at
+	// concurrent_requests, and hosts applies host_limits across them. They
+	// are created on first use.
+	slots      chan struct{}
+	hosts      *hostLimiter
+	limitsOnce sync.Once
+
+	// searchMu allows one scheduled search at a time, so profiles do not
+	// compete for the request slots
+	searchMu sync.Mutex
+
+	// lastRun is the ID of the run this Crawler last started or resumed
+	lastRun atomic.Int64
+}
+
+// Option configures a Crawler
+type Option func(*Crawler) error
+
+// WithConfig sets the crawler's settings; the default is DefaultConfig
+func WithConfig(cfg Config) Option {
+	return func(cr *Crawler) error {
+		cr.config = cfg
+		return nil
+	}
+}
+
+// WithLogger sets where the crawler logs; by default it logs nothing
+func WithLogger(logger *log.Logger) Option {
+	return func(cr *Crawler) error {
+		cr.logger = logger
+		return nil
+	}
+}
+
+// WithVerbose logs every request of every crawl, including those of
+// scheduled search profiles, as Query.Verbose does for one query
+func WithVerbose(verbose bool) Option {
+	return func(cr *Crawler) error {
+		cr.verbose = verbose
+		return nil
+	}
+}
+
+// WithResolver sets the DNS resolver emails are verified with; the default
+// is net.DefaultResolver
+func WithResolver(resolver Resolver) Option {
+	return func(cr *Crawler) error {
+		cr.resolver = resolver
+		return nil
+	}
+}
+
+// WithDatabase stores results, crawl progress and job postings in the
+// SQLite database at path, creating or upgrading it as needed. Without it
+// they are kept in memory for the life of the Crawler.
+func WithDatabase(path string) Option {
+	return func(cr *Crawler) error {
+		db, err := openDB(path)
+		if err != nil {
+			return err
+		}
+		cr.db, cr.closeDB = db, true
+		return nil
+	}
+}
+
+// WithoutMigrations leaves the schema of the database as it is, so it can
+// be inspected with Migrations before Migrate applies what is pending.
+// Crawling and reading results need an up-to-date schema.
+func WithoutMigrations() Option {
+	return func(cr *Crawler) error {
+		cr.skipMigrations = true
+		return nil
+	}
+}
+
+// New returns a Crawler with the given options applied
+func New(options ...Option) (*Crawler, error) {
+	cr := &Crawler{
+		config:   DefaultConfig(),
+		logger:   log.New(io.Discard, "", log.LstdFlags),
+		resolver: net.DefaultResolver,
+	}
+	for _, option := range options {
+		if err := option(cr); err != nil {
+			cr.Close()
+			return nil, err
+		}
+	}
+
+	if cr.db == nil {
+		db, err := openDB(":memory:")
+		if err != nil {
+			return nil, err
+		}
+		cr.db, cr.closeDB = db, true
+	}
+	if !cr.skipMigrations {
+		if err := cr.Migrate(); err != nil {
+			cr.Close()
+			return nil, err
+		}
+	}
+
+	cr.verifier = newEmailVerifier(cr.resolver, dnsTimeout, cr.logger)
+	cr.robots = newRobotsCache(cr.fetchRobotsTxt)
+	return cr, nil
+}
+
+// openDB opens a SQLite database. An in-memory one lives as long as its
+// single connection.
+func openDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows a single writer; serialize access from crawler goroutines
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// Close releases the crawler's database
+func (cr *Crawler) Close() error {
+	if cr.db != nil && cr.closeDB {
+		return cr.db.Close()
+	}
+	return nil
+}
+
+// Config returns the crawler's settings
+func (cr *Crawler) Config() Config {
+	return cr.config
+}
+
+// Query describes one search
+type Query struct {
+	// Locations are the cities or countries to search for
+	Locations []string
+	// Engines are search engine names, comma-separated, or "all"; empty
+	// means all
+	Engines string
+	// Templates override Config.QueryTemplates; see expandQueryTemplates
+	Templates []string
+	// LinkedIn also crawls LinkedIn job search results
+	LinkedIn bool
+	// Proxy sends requests through Config.ProxyAddress
+	Proxy bool
+	// Verbose logs every request; see also WithVerbose
+	Verbose bool
+	// Only passes on just the emails in these categories; see
+	// ParseCategories. Everything found is still saved in the database.
+	Only []string
+}
+
+func (q Query) engines() string {
+	if q.Engines == "" {
+		return "all"
+	}
+	return q.Engines
+}
+
+// ErrSomePagesFailed is wrapped by the error of a crawl that finished but
+// could not crawl some pages. The results of the other pages were saved
+// and passed on.
+var ErrSomePagesFailed = errors.New("some pages failed")
+
+// Run searches for the query's locations and crawls the sites found,
+// returning the results. Pages that fail are skipped; their errors are
+// returned together with the results of the other pages. A cancelled ctx
+// stops the crawl early.
+func (cr *Crawler) Run(ctx context.Context, q Query) ([]Result, error) {
+	results := make(chan Result)
+	errc := make(chan error, 1)
+	go func() { errc <- cr.Stream(ctx, q, results) }()
+
+	var found []Result
+	for result := range results {
+		found = append(found, result)
+	}
+	return found, <-errc
+}
+
+// Stream is like Run but sends each result on results as soon as its page
+// has been crawled, and closes results when the crawl ends. The caller must
+// keep receiving until then, or cancel ctx. A cancelled crawl can be
+// continued with Resume.
+func (cr *Crawler) Stream(ctx context.Context, q Query, results chan<- Result) error {
+	defer close(results)
+	return cr.crawl(ctx, q, func(result Result) {
+		select {
+		case results <- result:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// crawl runs a new crawl for q, passing each result to emit. An
+// interrupted crawl is left unfinished so it can be resumed.
+func (cr *Crawler) crawl(ctx context.Context, q Query, emit func(Result)) error {
+	if err := cr.config.Validate(); err != nil {
+		return err
+	}
+	categories, err := ParseCategories(strings.Join(q.Only, ","))
+	if err != nil {
+		return err
+	}
+	if err := cr.config.CheckQueryTemplates(q.Templates); err != nil {
+		return err
+	}
+
+	templates := cr.config.queryTemplates(q.Templates)
+	pages, err := cr.identifyTargetPages(ctx, q.engines(), q.LinkedIn, q.Locations, templates, q.Proxy)
+	if err != nil {
+		return fmt.Errorf("failed to identify target pages: %w", err)
+	}
+
+	run, err := cr.startRun(q.Locations, q.engines(), templates, q.LinkedIn)
+	if err != nil {
+		return err
+	}
+	return cr.crawlPages(ctx, run, pages, q, categories, emit)
+}
+
+// crawlPages crawls the pages found for run, passing each result with
+// emails in categories to emit, and finishes the run unless ctx is
+// cancelled first.
+func (cr *Crawler) crawlPages(ctx context.Context, run *crawlRun, pages []searchPage, q Query, categories []string, emit func(Result)) error {
+	cr.lastRun.Store(run.ID)
+
+	// Pages that failed are recorded in the frontier
+	extractErr := cr.extractEmails(ctx, run, pages, q.Proxy, q.Verbose || cr.verbose, func(result Result) {
+		if kept := filterCategories([]Result{result}, categories); len(kept) > 0 {
+			emit(kept[0])
+		}
+	})
+	cr.reportSkippedURLs(run)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := cr.finishRun(run); err != nil {
+		return err
+	}
+	if extractErr != nil {
+		return fmt.Errorf("%w: %v", ErrSomePagesFailed, extractErr)
+	}
+	return nil
+}
+
+// Resume continues the latest crawl that did not finish, with the
+// locations, engines, query templates and LinkedIn mode it was started
+// with; the other fields of q apply. The results the run saved before it
+// was interrupted are written to sink first. Without such a run it returns
+// ErrNoInterruptedRun.
+func (cr *Crawler) Resume(ctx context.Context, q Query, sink Sink) error {
+	if err := cr.config.Validate(); err != nil {
+		return err
+	}
+	categories, err := ParseCategories(strings.Join(q.Only, ","))
+	if err != nil {
+		return err
+	}
+
+	run, err := cr.lastInterruptedRun()
+	if err != nil {
+		return err
+	}
+	saved, err := cr.Results(ResultFilter{RunID: run.ID, Categories: categories})
+	if err != nil {
+		return fmt.Errorf("failed to resume: %w", err)
+	}
+	cr.logger.Printf("Resuming run %d for %s with %d saved results", run.ID, strings.Join(run.Locations, "; "), len(saved))
+
+	// The run's search pages are queued again; those already in its
+	// frontier keep their state
+	pages, err := cr.identifyTargetPages(ctx, run.Engines, run.LinkedIn, run.Locations, cr.config.queryTemplates(run.QueryTemplates), q.Proxy)
+	if err != nil {
+		return fmt.Errorf("failed to identify target pages: %w", err)
+	}
+
+	return cr.writeTo(sink, func(emit func(Result)) error {
+		for _, result := range saved {
+			emit(result)
+		}
+		return cr.crawlPages(ctx, run, pages, q, categories, emit)
+	})
+}
@@ -0,0 +1,94 @@
+package careerfind
+
+import (
+	"encoding/hex"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Obfuscated "at" and "dot" separators: [at], (at), {at}, <at>, [@], [dot],
+// (.) and the like, with optional spaces inside and around the brackets.
+// Only the brackets set them apart from prose, so a plain " at " or " @ "
+// is never taken for an address.
+const (
+	bracketAt  = `\s*(?:\[\s*(?:at|@)\s*\]|\(\s*(?:at|@)\s*\)|\{\s*(?:at|@)\s*\}|<\s*at\s*>)\s*`
+	bracketDot = `\s*(?:\[\s*(?:dot|\.)\s*\]|\(\s*(?:dot|\.)\s*\)|\{\s*(?:dot|\.)\s*\}|<\s*dot\s*>)\s*`
+	spelledDot = `\s+dot\s+`
+	localPart  = `[a-z0-9._%+-]+`
+	label      = `[a-z0-9-]+`
+	topLevel   = `[a-z]{2,}\b`
+)
+
+var (
+	// "jobs [at] acme.com" and "jobs(at)acme[dot]com": a bracketed at with
+	// any kind of dot. Once the at is bracketed, a spelled-out dot as in
+	// "jobs [at] acme dot com" is part of the address too.
+	bracketedEmailRegex = regexp.MustCompile(`(?i)` + localPart + bracketAt +
+		`(?:` + label + `(?:` + bracketDot + `|` + spelledDot + `|\.))+` + topLevel)
+
+	obfuscatedAtRegex  = regexp.MustCompile(`(?i)` + bracketAt)
+	obfuscatedDotRegex = regexp.MustCompile(`(?i)` + bracketDot + `|` + spelledDot)
+)
+
+// deobfuscateText rewrites obfuscated addresses in text as plain
+// user@domain.tld so the email regex can find them. It also decodes HTML
+// entities left in the text, such as double-encoded &amp;#64;.
+func deobfuscateText(text string) string {
+	if strings.Contains(text, "&") {
+		text = html.UnescapeString(text)
+	}
+
+	normalize := func(match string) string {
+		match = obfuscatedAtRegex.ReplaceAllString(match, "@")
+		return obfuscatedDotRegex.ReplaceAllString(match, ".")
+	}
+	return bracketedEmailRegex.ReplaceAllStringFunc(text, normalize)
+}
+
+// decodeCloudflareEmail decodes an address hidden by Cloudflare's email
+// obfuscation, given the hex string from a data-cfemail attribute or an
+// /cdn-cgi/l/email-protection# link. The first byte is the XOR key for the
+// rest.
+func decodeCloudflareEmail(encoded string) string {
+	data, err := hex.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(data) < 2 {
+		return ""
+	}
+
+	key := data[0]
+	decoded := make([]byte, len(data)-1)
+	for i, b := range data[1:] {
+		decoded[i] = b ^ key
+	}
+	return string(decoded)
+}
+
+// cloudflareEmailPath is the link target of Cloudflare-protected addresses
+const cloudflareEmailPath = "/cdn-cgi/l/email-protection"
+
+// cloudflareLinkEmail decodes the address in a Cloudflare email-protection
+// link, or returns "" if href is not one.
+func cloudflareLinkEmail(href string) string {
+	i := strings.Index(href, cloudflareEmailPath+"#")
+	if i < 0 {
+		return ""
+	}
+	return decodeCloudflareEmail(href[i+len(cloudflareEmailPath)+1:])
+}
+
+// mailtoAddress returns the address of a mailto: link without parameters,
+// decoding percent-escapes and HTML entities used to hide it.
+func mailtoAddress(href string) string {
+	href = html.UnescapeString(strings.TrimSpace(href))
+	if len(href) < 7 || !strings.EqualFold(href[:7], "mailto:") {
+		return ""
+	}
+
+	address := strings.Split(href[7:], "?")[0]
+	if unescaped, err := url.PathUnescape(address); err == nil {
+		address = unescaped
+	}
+	return deobfuscateText(strings.TrimSpace(address))
+}
@@ -0,0 +1,81 @@
+package careerfind
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// skipDomainFilter is the reason recorded for targets outside the allowed
+// domains or inside the denied ones
+const skipDomainFilter = "excluded by allowed_domains or denied_domains"
+
+// ParseDomainList splits a comma-separated list of domains, as given in
+// the environment or on the command line
+func ParseDomainList(spec string) []string {
+	var domains []string
+	for _, domain := range strings.Split(spec, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// validateDomainLists returns a description of each problem with the
+// allowed and denied domains
+func validateDomainLists(allowed []string, denied []string) []string {
+	var problems []string
+	for _, list := range []struct {
+		name    string
+		domains []string
+	}{{"allowed_domains", allowed}, {"denied_domains", denied}} {
+		for i, domain := range list.domains {
+			name := fmt.Sprintf("%s[%d]", list.name, i)
+			if domain == "" {
+				problems = append(problems, name+": domain cannot be empty")
+			} else if strings.ContainsAny(domain, "/:@ *") {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a domain name", name, domain))
+			}
+		}
+	}
+	for i, domain := range denied {
+		for _, a := range allowed {
+			if domain != "" && strings.EqualFold(domain, a) {
+				problems = append(problems, fmt.Sprintf("denied_domains[%d]: %q is also in allowed_domains", i, domain))
+			}
+		}
+	}
+	return problems
+}
+
+// domainMatches reports whether host is domain or one of its subdomains
+func domainMatches(host string, domain string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	domain = strings.Trim(strings.ToLower(domain), ".")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// crawlAllowed reports whether the site of rawURL may be crawled under
+// allowed_domains and denied_domains. Denied domains win over allowed ones.
+func (cfg Config) crawlAllowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	for _, domain := range cfg.DeniedDomains {
+		if domainMatches(host, domain) {
+			return false
+		}
+	}
+	if len(cfg.AllowedDomains) == 0 {
+		return true
+	}
+	for _, domain := range cfg.AllowedDomains {
+		if domainMatches(host, domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package careerfind
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gocolly/colly"
+)
+
+// SearchEngine is a web search backend used to discover career pages.
+// Engines are registered with RegisterSearchEngine and selected with -b.
+type SearchEngine interface {
+	// Name is the identifier used on the command line.
+	Name() string
+	// SearchURL returns the URL of the given zero-based results page for query.
+	SearchURL(query string, page int) string
+	// ResultLinks extracts organic result URLs from a results page document.
+	ResultLinks(e *colly.HTMLElement) []string
+	// MaxPages is the number of results pages fetched per query.
+	MaxPages() int
+}
+
+// Search engine registry
+var (
+	engines   = make(map[string]SearchEngine)
+	enginesMu sync.RWMutex
+)
+
+func init() {
+	RegisterSearchEngine(&SelectorEngine{
+		EngineName:  "google",
+		BaseURL:     "https://www.google.com/search",
+		ExtraParams: "num=100",
+		PageParam:   "start",
+		PageStep:    100,
+		Pages:       2,
+		// Organic results link through /url?q=<target>; ads, maps and
+		// the engine's own pages use other paths
+		LinkSelector:  `a[href^="/url?"]`,
+		RedirectParam: "q",
+		SkipDomains:   []string{"youtube.com"},
+	})
+	RegisterSearchEngine(&SelectorEngine{
+		EngineName:   "bing",
+		BaseURL:      "https://www.bing.com/search",
+		ExtraParams:  "count=50",
+		PageParam:    "first",
+		FirstPage:    1,
+		PageStep:     50,
+		Pages:        2,
+		LinkSelector: "li.b_algo h2 a[href]",
+	})
+	// The JavaScript-free endpoint is the only one that returns results
+	// without running scripts; it paginates via POST so only one page is used.
+	RegisterSearchEngine(&SelectorEngine{
+		EngineName:    "duckduckgo",
+		BaseURL:       "https://html.duckduckgo.com/html/",
+		Pages:         1,
+		LinkSelector:  "a.result__a[href]",
+		RedirectParam: "uddg",
+	})
+}
+
+// RegisterSearchEngine makes a search engine available by name. It panics if
+// the engine is nil or an engine with the same name is already registered.
+func RegisterSearchEngine(engine SearchEngine) {
+	if engine == nil {
+		panic("careerfind: RegisterSearchEngine engine is nil")
+	}
+
+	name := strings.ToLower(engine.Name())
+	if name == "" || name == "all" {
+		panic(fmt.Sprintf("careerfind: invalid search engine name %q", engine.Name()))
+	}
+
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+
+	if _, dup := engines[name]; dup {
+		panic("careerfind: RegisterSearchEngine called twice for engine " + name)
+	}
+	engines[name] = engine
+}
+
+// SearchEngines returns the names of all registered engines, sorted.
+func SearchEngines() []string {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveSearchEngines turns a comma-separated -b value into registered
+// engines. "all" selects every registered engine; unknown names are an error.
+func resolveSearchEngines(spec string) ([]SearchEngine, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" {
+		return nil, fmt.Errorf("no search engines specified (available: %s)", strings.Join(SearchEngines(), ","))
+	}
+
+	names := strings.Split(spec, ",")
+	if spec == "all" {
+		names = SearchEngines()
+	}
+
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+
+	var selected []SearchEngine
+	var unknown []string
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		engine, ok := engines[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		selected = append(selected, engine)
+	}
+
+	if len(unknown) > 0 {
+		available := make([]string, 0, len(engines))
+		for name := range engines {
+			available = append(available, name)
+		}
+		sort.Strings(available)
+		return nil, fmt.Errorf("unknown search engine(s): %s (available: %s)",
+			strings.Join(unknown, ","), strings.Join(available, ","))
+	}
+
+	return selected, nil
+}
+
+// SelectorEngine is a SearchEngine for backends that take the query as a URL
+// parameter and render organic results as links matching a CSS selector.
+// It covers most HTML search frontends, including self-hosted SearXNG.
+type SelectorEngine struct {
+	EngineName string
+	// BaseURL is the search endpoint without a query string.
+	BaseURL string
+	// QueryParam is the query parameter name; defaults to "q".
+	QueryParam string
+	// ExtraParams is an encoded query string appended to every request.
+	ExtraParams string
+	// PageParam is the pagination parameter; empty disables pagination.
+	PageParam string
+	// FirstPage and PageStep map the zero-based page to the PageParam value.
+	FirstPage int
+	PageStep  int
+	Pages     int
+	// LinkSelector matches result anchors on the results page.
+	LinkSelector string
+	// RedirectParam names the query parameter holding the real target when
+	// results are wrapped in a redirect link.
+	RedirectParam string
+	// SkipDomains are registrable domains whose links are never results,
+	// besides that of the engine itself, such as a video site it owns.
+	SkipDomains []string
+}
+
+func (s *SelectorEngine) Name() string {
+	return s.EngineName
+}
+
+func (s *SelectorEngine) MaxPages() int {
+	if s.Pages < 1 || s.PageParam == "" {
+		return 1
+	}
+	return s.Pages
+}
+
+func (s *SelectorEngine) SearchURL(query string, page int) string {
+	queryParam := s.QueryParam
+	if queryParam == "" {
+		queryParam = "q"
+	}
+
+	params := url.Values{}
+	params.Set(queryParam, query)
+	if s.PageParam != "" && page > 0 {
+		step := s.PageStep
+		if step < 1 {
+			step = 1
+		}
+		params.Set(s.PageParam, strconv.Itoa(s.FirstPage+page*step))
+	}
+
+	searchURL := s.BaseURL + "?" + params.Encode()
+	if s.ExtraParams != "" {
+		searchURL += "&" + s.ExtraParams
+	}
+	return searchURL
+}
+
+func (s *SelectorEngine) ResultLinks(e *colly.HTMLElement) []string {
+	var links []string
+	seen := make(map[string]bool)
+
+	e.ForEach(s.LinkSelector, func(_ int, el *colly.HTMLElement) {
+		link := el.Request.AbsoluteURL(el.Attr("href"))
+		if s.RedirectParam != "" {
+			link = unwrapRedirect(link, s.RedirectParam)
+		}
+
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return
+		}
+		// Skip navigation, account, cache and ad links pointing back at any
+		// of the engine's sites
+		domain := registrableDomain(parsed.Hostname())
+		if domain == registrableDomain(e.Request.URL.Hostname()) {
+			return
+		}
+		for _, skip := range s.SkipDomains {
+			if domain == skip {
+				return
+			}
+		}
+
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	})
+
+	return links
+}
+
+// unwrapRedirect returns the target of a search engine redirect link such as
+// /url?q=<target> or /l/?uddg=<target>, or the link itself if it is not one.
+func unwrapRedirect(link, param string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	if target := parsed.Query().Get(param); strings.HasPrefix(target, "http") {
+		return target
+	}
+	return link
+}
+
+// lookupSearchEngine returns the registered engine with the given name
+func lookupSearchEngine(name string) (SearchEngine, bool) {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+
+	engine, ok := engines[strings.ToLower(name)]
+	return engine, ok
+}
@@ -0,0 +1,163 @@
+package careerfind
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Crawl frontier states
+const (
+	frontierQueued     = "queued"
+	frontierInProgress = "in_progress"
+	frontierDone       = "done"
+	frontierFailed     = "failed"
+)
+
+// Frontier entry kinds
+const (
+	frontierSearch = "search"
+	frontierTarget = "target"
+)
+
+// Crawl run states
+const (
+	runRunning  = "running"
+	runFinished = "finished"
+)
+
+// ErrNoInterruptedRun is returned by Resume when every run has finished
+var ErrNoInterruptedRun = errors.New("no interrupted run to resume")
+
+// crawlRun is one invocation of the crawler, persisted so it can be resumed
+type crawlRun struct {
+	ID             int64
+	Locations      []string
+	Engines        string
+	QueryTemplates []string
+	LinkedIn       bool
+	StartedAt      time.Time
+}
+
+// frontierEntry is a URL waiting to be fetched as part of a run
+type frontierEntry struct {
+	URL      string
+	Location string
+	Query    string
+	Engine   string
+	Attempts int
+}
+
+// startRun records a new crawl run
+func (cr *Crawler) startRun(locations []string, engines string, templates []string, linkedin bool) (*crawlRun, error) {
+	run := &crawlRun{
+		Locations:      locations,
+		Engines:        engines,
+		QueryTemplates: templates,
+		LinkedIn:       linkedin,
+		StartedAt:      time.Now().UTC(),
+	}
+
+	res, err := cr.db.Exec(`INSERT INTO crawl_runs (location, engines, query_templates, linkedin, status, started_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		strings.Join(locations, "\n"), engines, strings.Join(templates, "\n"), linkedin, runRunning, run.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record crawl run: %w", err)
+	}
+	if run.ID, err = res.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("failed to read crawl run id: %w", err)
+	}
+	return run, nil
+}
+
+// lastInterruptedRun returns the most recent run that never finished and
+// requeues any URLs it was fetching when it stopped.
+func (cr *Crawler) lastInterruptedRun() (*crawlRun, error) {
+	run := &crawlRun{}
+	var locations, templates string
+	err := cr.db.QueryRow(`SELECT id, location, engines, query_templates, linkedin, started_at FROM crawl_runs
+		WHERE status = ? ORDER BY id DESC LIMIT 1`, runRunning).
+		Scan(&run.ID, &locations, &run.Engines, &templates, &run.LinkedIn, &run.StartedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoInterruptedRun
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up interrupted run: %w", err)
+	}
+	run.Locations = strings.Split(locations, "\n")
+	if templates != "" {
+		run.QueryTemplates = strings.Split(templates, "\n")
+	}
+
+	if _, err := cr.db.Exec(`UPDATE frontier SET state = ?, updated_at = ? WHERE run_id = ? AND state = ?`,
+		frontierQueued, time.Now().UTC(), run.ID, frontierInProgress); err != nil {
+		return nil, fmt.Errorf("failed to requeue in-progress URLs: %w", err)
+	}
+	return run, nil
+}
+
+// finishRun marks a run as complete so it is no longer resumable
+func (cr *Crawler) finishRun(run *crawlRun) error {
+	if _, err := cr.db.Exec(`UPDATE crawl_runs SET status = ?, finished_at = ? WHERE id = ?`,
+		runFinished, time.Now().UTC(), run.ID); err != nil {
+		return fmt.Errorf("failed to finish crawl run: %w", err)
+	}
+	return nil
+}
+
+// enqueueURL adds a URL to the run's frontier unless it is already queued
+// for the same location
+func (cr *Crawler) enqueueURL(run *crawlRun, kind string, entry frontierEntry) error {
+	if _, err := cr.db.Exec(`INSERT OR IGNORE INTO frontier (run_id, kind, url, location, query, engine, state, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, kind, entry.URL, entry.Location, entry.Query, entry.Engine, frontierQueued, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to enqueue %s: %w", entry.URL, err)
+	}
+	return nil
+}
+
+// pendingURLs returns the queued entries of a kind, plus failed ones that
+// have been retried fewer than max_retries times.
+func (cr *Crawler) pendingURLs(run *crawlRun, kind string) ([]frontierEntry, error) {
+	rows, err := cr.db.Query(`SELECT url, location, query, engine, attempts FROM frontier
+		WHERE run_id = ? AND kind = ? AND (state = ? OR (state = ? AND attempts <= ?))
+		ORDER BY id`,
+		run.ID, kind, frontierQueued, frontierFailed, cr.config.MaxRetries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load frontier: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []frontierEntry
+	for rows.Next() {
+		var entry frontierEntry
+		if err := rows.Scan(&entry.URL, &entry.Location, &entry.Query, &entry.Engine, &entry.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to read frontier entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// markURL moves a frontier entry, identified by its URL and location, to a
+// new state. Entering the in-progress state counts as an attempt; a non-nil
+// cause is kept as the last error.
+func (cr *Crawler) markURL(run *crawlRun, entry frontierEntry, state string, cause error) error {
+	var lastError sql.NullString
+	if cause != nil {
+		lastError = sql.NullString{String: cause.Error(), Valid: true}
+	}
+
+	attempt := 0
+	if state == frontierInProgress {
+		attempt = 1
+	}
+
+	if _, err := cr.db.Exec(`UPDATE frontier SET state = ?, attempts = attempts + ?, last_error = COALESCE(?, last_error), updated_at = ?
+		WHERE run_id = ? AND url = ? AND location = ?`,
+		state, attempt, lastError, time.Now().UTC(), run.ID, entry.URL, entry.Location); err != nil {
+		return fmt.Errorf("failed to mark %s as %s: %w", entry.URL, state, err)
+	}
+	return nil
+}
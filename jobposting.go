@@ -0,0 +1,341 @@
+package careerfind
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+)
+
+// JobPosting is a schema.org JobPosting published on a page as JSON-LD or
+// microdata.
+type JobPosting struct {
+	Title        string `json:"title"`
+	Organization string `json:"organization,omitempty"`
+	// Company is the registrable domain of the hiring organization; see
+	// postingCompany
+	Company  string `json:"company"`
+	Location string `json:"location,omitempty"`
+	// DatePosted is a YYYY-MM-DD date
+	DatePosted     string `json:"date_posted,omitempty"`
+	EmploymentType string `json:"employment_type,omitempty"`
+	ContactEmail   string `json:"contact_email,omitempty"`
+	// URL is the posting's own address and Source the page it was found on
+	URL       string    `json:"url,omitempty"`
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// schemaNode is a decoded schema.org object. Microdata items are converted
+// to the same shape as JSON-LD so both are read by jobPostingFromNode.
+type schemaNode map[string]interface{}
+
+// extractJobPostings returns the job postings published on a page
+func extractJobPostings(e *colly.HTMLElement, source string) []JobPosting {
+	var postings []JobPosting
+	e.ForEach(`script[type="application/ld+json"]`, func(_ int, el *colly.HTMLElement) {
+		postings = append(postings, parseJSONLDJobPostings(el.Text, source)...)
+	})
+	e.ForEach(`[itemscope][itemtype*="schema.org/JobPosting"]`, func(_ int, el *colly.HTMLElement) {
+		if posting, ok := jobPostingFromNode(microdataJobPosting(el), source); ok {
+			postings = append(postings, posting)
+		}
+	})
+	return uniqueJobPostings(postings)
+}
+
+// parseJSONLDJobPostings returns the job postings in a JSON-LD script,
+// including those inside arrays and @graph lists. Malformed JSON yields
+// none.
+func parseJSONLDJobPostings(data string, source string) []JobPosting {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &doc); err != nil {
+		return nil
+	}
+
+	var postings []JobPosting
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]interface{}:
+			node := schemaNode(v)
+			if node.hasType("JobPosting") {
+				if posting, ok := jobPostingFromNode(node, source); ok {
+					postings = append(postings, posting)
+				}
+				return
+			}
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+	walk(doc)
+	return postings
+}
+
+// jobPostingFromNode reads a JobPosting object. It reports false for
+// postings without a title.
+func jobPostingFromNode(node schemaNode, source string) (JobPosting, bool) {
+	posting := JobPosting{
+		Title:          node.text("title"),
+		Organization:   node.text("hiringOrganization"),
+		Location:       node.location(),
+		DatePosted:     normalizeDate(node.text("datePosted")),
+		EmploymentType: node.text("employmentType"),
+		URL:            node.text("url"),
+		Source:         source,
+		Timestamp:      time.Now().UTC(),
+	}
+	if posting.Title == "" {
+		posting.Title = node.text("name")
+	}
+	if posting.Title == "" {
+		return JobPosting{}, false
+	}
+
+	for _, contact := range []schemaNode{node.node("applicantContact"), node.node("hiringOrganization")} {
+		email := contact.text("email")
+		if address := mailtoAddress(email); address != "" {
+			email = address
+		}
+		if isValidEmail(email) {
+			posting.ContactEmail = email
+			break
+		}
+	}
+
+	posting.Company = postingCompany(posting, node.node("hiringOrganization").text("url"))
+	return posting, true
+}
+
+// postingCompany returns the company domain of a posting: that of the
+// hiring organization's website, else of its contact email, else of the
+// page it was found on.
+func postingCompany(posting JobPosting, organizationURL string) string {
+	if u, err := url.Parse(organizationURL); err == nil && u.Hostname() != "" {
+		return registrableDomain(u.Hostname())
+	}
+	if posting.ContactEmail != "" {
+		return emailCompany(posting.ContactEmail, posting.Source)
+	}
+	if u, err := url.Parse(posting.Source); err == nil {
+		return registrableDomain(u.Hostname())
+	}
+	return ""
+}
+
+// hasType reports whether the node's @type includes typ
+func (n schemaNode) hasType(typ string) bool {
+	types, ok := n["@type"].([]interface{})
+	if !ok {
+		types = []interface{}{n["@type"]}
+	}
+	for _, t := range types {
+		if s, ok := t.(string); ok && strings.TrimPrefix(strings.TrimPrefix(s, "http://schema.org/"), "https://schema.org/") == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// node returns the first object under key, or nil
+func (n schemaNode) node(key string) schemaNode {
+	switch v := n[key].(type) {
+	case map[string]interface{}:
+		return v
+	case []interface{}:
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// text returns the value under key as text. Objects are read by their name
+// or @value, lists by their first entry with text.
+func (n schemaNode) text(key string) string {
+	return schemaText(n[key])
+}
+
+func schemaText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return collapseSpace(html.UnescapeString(v))
+	case float64:
+		return fmt.Sprint(v)
+	case map[string]interface{}:
+		if name := schemaText(v["name"]); name != "" {
+			return name
+		}
+		return schemaText(v["@value"])
+	case []interface{}:
+		for _, item := range v {
+			if s := schemaText(item); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// location describes where the job is: the addresses of its jobLocation
+// places, or Remote for telecommuting jobs without one.
+func (n schemaNode) location() string {
+	places, ok := n["jobLocation"].([]interface{})
+	if !ok {
+		places = []interface{}{n["jobLocation"]}
+	}
+
+	var locations []string
+	for _, place := range places {
+		var location string
+		switch place := place.(type) {
+		case string:
+			location = collapseSpace(place)
+		case map[string]interface{}:
+			location = placeAddress(place)
+		}
+		if location != "" && !containsString(locations, location) {
+			locations = append(locations, location)
+		}
+	}
+
+	if len(locations) == 0 && strings.EqualFold(n.text("jobLocationType"), "TELECOMMUTE") {
+		return "Remote"
+	}
+	return strings.Join(locations, "; ")
+}
+
+// placeAddress formats a Place as "locality, region, country"
+func placeAddress(place schemaNode) string {
+	address := place.node("address")
+	if address == nil {
+		if s, ok := place["address"].(string); ok {
+			return collapseSpace(s)
+		}
+		return place.text("name")
+	}
+
+	var parts []string
+	for _, key := range []string{"addressLocality", "addressRegion", "addressCountry"} {
+		if part := address.text(key); part != "" && !containsString(parts, part) {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// microdataJobPosting reads a microdata JobPosting item into a schemaNode
+func microdataJobPosting(el *colly.HTMLElement) schemaNode {
+	// The address is usually a PostalAddress item of the jobLocation Place,
+	// but may be given on the jobLocation itself
+	address := func(prop string) string {
+		return firstNonEmpty(microdataProp(el, "jobLocation", "address", prop), microdataProp(el, "jobLocation", prop))
+	}
+	return schemaNode{
+		"@type":          "JobPosting",
+		"title":          microdataProp(el, "title"),
+		"employmentType": microdataProp(el, "employmentType"),
+		"datePosted":     microdataProp(el, "datePosted"),
+		"url":            microdataProp(el, "url"),
+		"hiringOrganization": map[string]interface{}{
+			"name":  firstNonEmpty(microdataProp(el, "hiringOrganization", "name"), microdataProp(el, "hiringOrganization")),
+			"url":   microdataProp(el, "hiringOrganization", "url"),
+			"email": microdataProp(el, "hiringOrganization", "email"),
+		},
+		"jobLocation": map[string]interface{}{
+			"address": map[string]interface{}{
+				"addressLocality": address("addressLocality"),
+				"addressRegion":   address("addressRegion"),
+				"addressCountry":  address("addressCountry"),
+			},
+		},
+		"applicantContact": map[string]interface{}{
+			"email": microdataProp(el, "applicantContact", "email"),
+		},
+	}
+}
+
+// microdataProp returns the value of the property at path below el, from
+// its content, datetime or href attribute or else its text. Each step only
+// matches properties of the item itself, so the posting's url is never
+// taken from the hiringOrganization nested in it.
+func microdataProp(el *colly.HTMLElement, path ...string) string {
+	prop := el.DOM
+	for _, name := range path {
+		if prop = itemProperty(prop, name); prop == nil {
+			return ""
+		}
+	}
+
+	for _, attr := range []string{"content", "datetime", "href"} {
+		if value := strings.TrimSpace(prop.AttrOr(attr, "")); value != "" {
+			return value
+		}
+	}
+	return strings.TrimSpace(prop.Text())
+}
+
+// itemProperty returns the first element below item with the itemprop name
+// that is not inside another item nested in it, or nil if there is none.
+func itemProperty(item *goquery.Selection, name string) *goquery.Selection {
+	var prop *goquery.Selection
+	item.Find(fmt.Sprintf("[itemprop~=%q]", name)).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if s.ParentsUntilSelection(item).Filter("[itemscope]").Length() > 0 {
+			return true
+		}
+		prop = s
+		return false
+	})
+	return prop
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// normalizeDate returns a schema.org date or date-time as YYYY-MM-DD, or ""
+// if it cannot be read.
+func normalizeDate(value string) string {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	if len(value) > 10 {
+		return normalizeDate(value[:10])
+	}
+	return ""
+}
+
+// uniqueJobPostings drops postings repeated on a page, such as one
+// published as both JSON-LD and microdata.
+func uniqueJobPostings(postings []JobPosting) []JobPosting {
+	var unique []JobPosting
+	seen := make(map[string]bool)
+	for _, posting := range postings {
+		key := strings.ToLower(posting.Title + "\x00" + posting.Location)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, posting)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,100 @@
+package careerfind
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// jsonlFilename is the JSON Lines output in output_dir. Runs append to it,
+// so it holds every sighting until it is moved away.
+const jsonlFilename = "results.jsonl"
+
+// emailSighting is one line of the JSON Lines output: an email seen on a
+// page, with the fields of the CSV output
+type emailSighting struct {
+	Email         string    `json:"email"`
+	Category      string    `json:"category"`
+	Verification  string    `json:"verification"`
+	Company       string    `json:"company"`
+	CompanyDomain string    `json:"company_domain"`
+	Location      string    `json:"location"`
+	Query         string    `json:"query"`
+	Timestamp     time.Time `json:"timestamp"`
+	Source        string    `json:"source"`
+	PageTitle     string    `json:"page_title,omitempty"`
+	JobTitle      string    `json:"job_title,omitempty"`
+	Heading       string    `json:"heading,omitempty"`
+	Snippet       string    `json:"snippet,omitempty"`
+}
+
+// writeJSONLResult writes one line for each email of result
+func writeJSONLResult(w io.Writer, result Result) error {
+	encoder := json.NewEncoder(w)
+	for _, email := range result.Emails {
+		if err := encoder.Encode(emailSighting{
+			Email:         email,
+			Category:      result.Category(email),
+			Verification:  result.Verification(email),
+			Company:       companyName(result.Company(email)),
+			CompanyDomain: result.Company(email),
+			Location:      result.Location,
+			Query:         result.Query,
+			Timestamp:     result.Timestamp,
+			Source:        result.Source,
+			PageTitle:     result.PageTitle,
+			JobTitle:      result.JobTitle,
+			Heading:       result.Details[email].Heading,
+			Snippet:       result.Details[email].Snippet,
+		}); err != nil {
+			return fmt.Errorf("failed to write JSON line: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveJSONL writes batch to a new JSON Lines file, for export
+func saveJSONL(filename string, batch []Result) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	for _, result := range batch {
+		if err := writeJSONLResult(file, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonlSink appends each email to results.jsonl in output_dir as soon as it
+// is found
+type jsonlSink struct {
+	crawler *Crawler
+	file    *os.File
+}
+
+func (s *jsonlSink) Open() error {
+	if err := os.MkdirAll(s.crawler.config.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Join(s.crawler.config.OutputDir, jsonlFilename), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open JSON Lines output: %w", err)
+	}
+	s.file = file
+	return nil
+}
+
+func (s *jsonlSink) Write(result Result) error {
+	return writeJSONLResult(s.file, result)
+}
+
+func (s *jsonlSink) Close() error {
+	return s.file.Close()
+}
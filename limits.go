@@ -0,0 +1,223 @@
+package careerfind
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit throttles requests to the hosts matching Domain, a glob such as
+// "*.linkedin.com" or "*". The first matching limit applies to all requests
+// to a host, whichever sites they are crawled for.
+type HostLimit struct {
+	Domain string `json:"domain"`
+	// Parallelism is the number of simultaneous requests to a host; 0 means
+	// no limit.
+	Parallelism int `json:"parallelism"`
+	// DelayMs is the pause between requests to a host, plus a random extra
+	// of up to RandomDelayMs.
+	DelayMs       int `json:"delay_ms"`
+	RandomDelayMs int `json:"random_delay_ms"`
+}
+
+// defaultHostLimits apply when the config has no host_limits
+var defaultHostLimits = []HostLimit{{Domain: "*", Parallelism: 2}}
+
+// defaultConcurrentRequests is used when concurrent_requests is not set
+const defaultConcurrentRequests = 5
+
+func (cfg Config) hostLimits() []HostLimit {
+	if len(cfg.HostLimits) == 0 {
+		return defaultHostLimits
+	}
+	return cfg.HostLimits
+}
+
+// hostLimiter applies host limits to the requests of all collectors, so a
+// host's parallelism and delay hold however many of its pages are crawled
+// at once.
+type hostLimiter struct {
+	limits []HostLimit
+
+	mu    sync.Mutex
+	hosts map[string]*hostThrottle
+}
+
+// hostThrottle is the state of one host under its limit
+type hostThrottle struct {
+	limit HostLimit
+	// slots holds a token per request in flight; nil means no limit
+	slots chan struct{}
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newHostLimiter(limits []HostLimit) *hostLimiter {
+	return &hostLimiter{limits: limits, hosts: make(map[string]*hostThrottle)}
+}
+
+// host returns the throttle of host, or nil if no limit matches it
+func (l *hostLimiter) host(host string) *hostThrottle {
+	host = strings.ToLower(host)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t, ok := l.hosts[host]; ok {
+		return t
+	}
+
+	var t *hostThrottle
+	for _, limit := range l.limits {
+		if ok, _ := path.Match(limit.Domain, host); ok {
+			t = &hostThrottle{limit: limit}
+			if limit.Parallelism > 0 {
+				t.slots = make(chan struct{}, limit.Parallelism)
+			}
+			break
+		}
+	}
+	l.hosts[host] = t
+	return t
+}
+
+// acquire waits for one of the host's slots and for its delay since the
+// previous request, and returns the function freeing the slot
+func (t *hostThrottle) acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-t.slots }
+	}
+
+	delay := time.Duration(t.limit.DelayMs) * time.Millisecond
+	if t.limit.RandomDelayMs > 0 {
+		delay += time.Duration(rand.Int63n(int64(t.limit.RandomDelayMs)+1)) * time.Millisecond
+	}
+	if delay == 0 {
+		return release, nil
+	}
+
+	// Reserve the next start time, so waiting requests go one delay apart
+	t.mu.Lock()
+	start := time.Now()
+	if t.next.After(start) {
+		start = t.next
+	}
+	t.next = start.Add(delay)
+	t.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return release, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// validateHostLimits returns a description of each problem with limits
+func validateHostLimits(limits []HostLimit) []string {
+	var problems []string
+	for i, limit := range limits {
+		name := fmt.Sprintf("host_limits[%d]", i)
+		if limit.Domain == "" {
+			problems = append(problems, name+": domain cannot be empty")
+		} else if _, err := path.Match(limit.Domain, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid domain glob %q", name, limit.Domain))
+		}
+		if limit.Parallelism < 0 {
+			problems = append(problems, name+": parallelism cannot be negative")
+		}
+		if limit.DelayMs < 0 || limit.RandomDelayMs < 0 {
+			problems = append(problems, name+": delays cannot be negative")
+		}
+	}
+	return problems
+}
+
+// requestLimits returns the request slots and host limits shared by the
+// crawler's collectors
+func (cr *Crawler) requestLimits() (chan struct{}, *hostLimiter) {
+	cr.limitsOnce.Do(func() {
+		cr.slots = make(chan struct{}, cr.config.maxConcurrentRequests())
+		cr.hosts = newHostLimiter(cr.config.hostLimits())
+	})
+	return cr.slots, cr.hosts
+}
+
+func (cfg Config) maxConcurrentRequests() int {
+	if cfg.ConcurrentRequests <= 0 {
+		return defaultConcurrentRequests
+	}
+	return cfg.ConcurrentRequests
+}
+
+// limitedTransport holds one of the shared request slots, and one of the
+// host's slots under its host limit, from sending a request until its
+// response body is closed.
+type limitedTransport struct {
+	base  http.RoundTripper
+	slots chan struct{}
+	// hosts may be nil for no host limits
+	hosts *hostLimiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Wait for the host first, so a throttled host does not hold a slot
+	// other hosts could use
+	releaseHost := func() {}
+	if t.hosts != nil {
+		if throttle := t.hosts.host(req.URL.Hostname()); throttle != nil {
+			release, err := throttle.acquire(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			releaseHost = release
+		}
+	}
+
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		releaseHost()
+		return nil, req.Context().Err()
+	}
+	release := func() {
+		<-t.slots
+		releaseHost()
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody frees a request slot when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,100 @@
+package careerfind
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadLocations expands location values, such as those given with -L or in
+// a search profile, into the locations to search. A value
+// starting with @ names a file with one location per line; blank lines and
+// lines starting with # are skipped. Duplicates are dropped.
+func LoadLocations(values []string) ([]string, error) {
+	var locations []string
+	seen := make(map[string]bool)
+	add := func(location string) {
+		location = strings.TrimSpace(location)
+		if location != "" && !seen[strings.ToLower(location)] {
+			seen[strings.ToLower(location)] = true
+			locations = append(locations, location)
+		}
+	}
+
+	for _, value := range values {
+		if !strings.HasPrefix(value, "@") {
+			add(value)
+			continue
+		}
+
+		fileLocations, err := readLocationsFile(strings.TrimPrefix(value, "@"))
+		if err != nil {
+			return nil, err
+		}
+		for _, location := range fileLocations {
+			add(location)
+		}
+	}
+
+	if len(locations) == 0 {
+		return nil, errors.New("at least one location is required (-L)")
+	}
+	return locations, nil
+}
+
+func readLocationsFile(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open locations file: %w", err)
+	}
+	defer file.Close()
+
+	var locations []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		locations = append(locations, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read locations file: %w", err)
+	}
+	return locations, nil
+}
+
+// locationGroup is the results found for one location
+type locationGroup struct {
+	Location string
+	Results  []Result
+}
+
+// groupByLocation splits results by location, keeping locations in the
+// order they first appear and results in their original order.
+func groupByLocation(batch []Result) []locationGroup {
+	var groups []locationGroup
+	index := make(map[string]int)
+	for _, result := range batch {
+		i, ok := index[result.Location]
+		if !ok {
+			i = len(groups)
+			index[result.Location] = i
+			groups = append(groups, locationGroup{Location: result.Location})
+		}
+		groups[i].Results = append(groups[i].Results, result)
+	}
+	return groups
+}
+
+// SortByLocation returns batch reordered so results for the same location
+// are adjacent
+func SortByLocation(batch []Result) []Result {
+	sorted := make([]Result, 0, len(batch))
+	for _, group := range groupByLocation(batch) {
+		sorted = append(sorted, group.Results...)
+	}
+	return sorted
+}
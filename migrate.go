@@ -0,0 +1,253 @@
+package careerfind
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Up-migrations are numbered SQL files applied in order, each in its own
+// transaction. Never edit a released migration; add a new one instead.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration is one embedded schema change
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// appliedMigration is a row of the schema_version table
+type appliedMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+const createSchemaVersionSQL = `CREATE TABLE IF NOT EXISTS schema_version (
+	"version" INTEGER NOT NULL PRIMARY KEY,
+	"name" TEXT NOT NULL,
+	"applied_at" DATETIME NOT NULL
+);`
+
+// loadMigrations returns the embedded migrations sorted by version
+func loadMigrations() ([]migration, error) {
+	names, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, entry := range names {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s does not start with a version number", entry.Name())
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		body, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// migrateDB brings the database schema up to the latest embedded version
+func (cr *Crawler) migrateDB() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if err := cr.baselineLegacySchema(migrations); err != nil {
+		return err
+	}
+
+	current, err := cr.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := cr.applyMigration(m); err != nil {
+			return err
+		}
+		cr.logger.Printf("Applied migration %s", m.Name)
+	}
+
+	return nil
+}
+
+func (cr *Crawler) applyMigration(m migration) error {
+	tx, err := cr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start migration %s: %w", m.Name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("migration %s failed: %w", m.Name, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.Name, err)
+	}
+	return nil
+}
+
+// baselineLegacySchema creates schema_version and, for databases created
+// before migrations were versioned, records the migrations their existing
+// tables already correspond to.
+func (cr *Crawler) baselineLegacySchema(migrations []migration) error {
+	tracked, err := cr.schemaTracked()
+	if err != nil {
+		return err
+	}
+	if tracked {
+		return nil
+	}
+
+	// Each legacy layout is identified by the newest table it introduced
+	baseline := 0
+	for version, table := range []string{"results", "frontier", "sightings"} {
+		var exists int
+		if err := cr.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to inspect legacy schema: %w", err)
+		}
+		if exists > 0 {
+			baseline = version + 1
+		}
+	}
+
+	tx, err := cr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start schema baseline: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(createSchemaVersionSQL); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	for _, m := range migrations {
+		if m.Version > baseline {
+			break
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.Version, m.Name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to record baseline migration %s: %w", m.Name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schema baseline: %w", err)
+	}
+	if baseline > 0 {
+		cr.logger.Printf("Recorded existing schema as version %d", baseline)
+	}
+	return nil
+}
+
+// schemaTracked reports whether the database has a schema_version table
+func (cr *Crawler) schemaTracked() (bool, error) {
+	var tracked int
+	if err := cr.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&tracked); err != nil {
+		return false, fmt.Errorf("failed to check schema version: %w", err)
+	}
+	return tracked > 0, nil
+}
+
+// schemaVersion returns the highest applied migration version
+func (cr *Crawler) schemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := cr.db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// appliedMigrations returns the schema_version rows by version
+func (cr *Crawler) appliedMigrations() (map[int]appliedMigration, error) {
+	rows, err := cr.db.Query(`SELECT version, name, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_version: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var m appliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_version row: %w", err)
+		}
+		applied[m.Version] = m
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus is an embedded schema migration and when it was applied
+// to the crawler's database
+type MigrationStatus struct {
+	Version int
+	Name    string
+	// AppliedAt is zero for a pending migration
+	AppliedAt time.Time
+}
+
+// Migrations returns the status of every embedded migration, by version.
+// It does not change the database: one from before versioned migrations
+// has every migration pending until it is migrated.
+func (cr *Crawler) Migrations() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	tracked, err := cr.schemaTracked()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]appliedMigration)
+	if tracked {
+		if applied, err = cr.appliedMigrations(); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name, AppliedAt: applied[m.Version].AppliedAt}
+	}
+	return statuses, nil
+}
+
+// Migrate applies the pending migrations. New does this unless it is given
+// WithoutMigrations.
+func (cr *Crawler) Migrate() error {
+	if err := cr.migrateDB(); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return nil
+}
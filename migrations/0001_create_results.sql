@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS results (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"emails" TEXT,
+	"location" TEXT,
+	"timestamp" DATETIME,
+	"source" TEXT
+);
@@ -0,0 +1,27 @@
+CREATE TABLE crawl_runs (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"location" TEXT NOT NULL,
+	"engines" TEXT NOT NULL,
+	"linkedin" BOOLEAN NOT NULL DEFAULT 0,
+	"status" TEXT NOT NULL,
+	"started_at" DATETIME NOT NULL,
+	"finished_at" DATETIME
+);
+
+CREATE TABLE frontier (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"run_id" INTEGER NOT NULL REFERENCES crawl_runs(id),
+	"kind" TEXT NOT NULL,
+	"url" TEXT NOT NULL,
+	"query" TEXT NOT NULL,
+	"engine" TEXT NOT NULL DEFAULT '',
+	"state" TEXT NOT NULL,
+	"attempts" INTEGER NOT NULL DEFAULT 0,
+	"last_error" TEXT,
+	"updated_at" DATETIME NOT NULL,
+	UNIQUE ("run_id", "url")
+);
+
+CREATE INDEX frontier_run_state ON frontier ("run_id", "kind", "state");
+
+ALTER TABLE results ADD COLUMN "run_id" INTEGER REFERENCES crawl_runs(id);
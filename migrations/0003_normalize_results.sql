@@ -0,0 +1,64 @@
+-- Every address and page is stored once, and a sighting links an email to
+-- the page it was found on during a run. Run 0 holds sightings that predate
+-- crawl runs.
+CREATE TABLE emails (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"address" TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	"first_seen" DATETIME NOT NULL,
+	"last_seen" DATETIME NOT NULL
+);
+
+CREATE TABLE sources (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"url" TEXT NOT NULL UNIQUE,
+	"first_seen" DATETIME NOT NULL,
+	"last_seen" DATETIME NOT NULL
+);
+
+CREATE TABLE sightings (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"email_id" INTEGER NOT NULL REFERENCES emails(id),
+	"source_id" INTEGER NOT NULL REFERENCES sources(id),
+	"run_id" INTEGER NOT NULL DEFAULT 0,
+	"query" TEXT NOT NULL,
+	"first_seen" DATETIME NOT NULL,
+	"last_seen" DATETIME NOT NULL,
+	UNIQUE ("email_id", "source_id", "run_id")
+);
+
+CREATE INDEX sightings_run ON sightings ("run_id");
+
+-- Split the comma-joined emails column into one row per address
+CREATE TEMP TABLE legacy_sightings AS
+WITH RECURSIVE split(result_id, address, rest) AS (
+	SELECT id, '', COALESCE(emails, '') || ',' FROM results
+	UNION ALL
+	SELECT result_id,
+		TRIM(SUBSTR(rest, 1, INSTR(rest, ',') - 1)),
+		SUBSTR(rest, INSTR(rest, ',') + 1)
+	FROM split WHERE rest <> ''
+)
+SELECT split.address AS address,
+	results.source AS source,
+	COALESCE(results.run_id, 0) AS run_id,
+	COALESCE(results.location, '') AS query,
+	COALESCE(results.timestamp, CURRENT_TIMESTAMP) AS seen
+FROM split JOIN results ON results.id = split.result_id
+WHERE split.address <> '' AND COALESCE(results.source, '') <> '';
+
+INSERT INTO emails (address, first_seen, last_seen)
+SELECT address, MIN(seen), MAX(seen) FROM legacy_sightings GROUP BY LOWER(address);
+
+INSERT INTO sources (url, first_seen, last_seen)
+SELECT source, MIN(seen), MAX(seen) FROM legacy_sightings GROUP BY source;
+
+INSERT INTO sightings (email_id, source_id, run_id, query, first_seen, last_seen)
+SELECT emails.id, sources.id, legacy.run_id, MIN(legacy.query), MIN(legacy.seen), MAX(legacy.seen)
+FROM legacy_sightings legacy
+JOIN emails ON emails.address = legacy.address
+JOIN sources ON sources.url = legacy.source
+GROUP BY emails.id, sources.id, legacy.run_id;
+
+DROP TABLE legacy_sightings;
+
+ALTER TABLE results RENAME TO results_legacy;
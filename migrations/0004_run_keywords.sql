@@ -0,0 +1,3 @@
+-- Keyword phrases searched with the run's location, one per line, so a
+-- resumed run repeats the same queries
+ALTER TABLE crawl_runs ADD COLUMN "keywords" TEXT NOT NULL DEFAULT '';
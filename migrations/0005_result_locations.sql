@@ -0,0 +1,53 @@
+-- Runs may search several locations, stored one per line in
+-- crawl_runs.location. Frontier entries and sightings record the location
+-- whose search surfaced them, separately from the query. A page surfaced by
+-- several locations of a run is queued and sighted once per location, so
+-- both tables are rebuilt to key on the location as well.
+CREATE TABLE frontier_new (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"run_id" INTEGER NOT NULL REFERENCES crawl_runs(id),
+	"kind" TEXT NOT NULL,
+	"url" TEXT NOT NULL,
+	"location" TEXT NOT NULL DEFAULT '',
+	"query" TEXT NOT NULL,
+	"engine" TEXT NOT NULL DEFAULT '',
+	"state" TEXT NOT NULL,
+	"attempts" INTEGER NOT NULL DEFAULT 0,
+	"last_error" TEXT,
+	"updated_at" DATETIME NOT NULL,
+	UNIQUE ("run_id", "url", "location")
+);
+
+-- Earlier runs searched a single location
+INSERT INTO frontier_new (id, run_id, kind, url, location, query, engine, state, attempts, last_error, updated_at)
+SELECT id, run_id, kind, url, COALESCE((SELECT location FROM crawl_runs WHERE crawl_runs.id = frontier.run_id), ''),
+	query, engine, state, attempts, last_error, updated_at
+FROM frontier;
+
+DROP TABLE frontier;
+ALTER TABLE frontier_new RENAME TO frontier;
+
+CREATE INDEX frontier_run_state ON frontier ("run_id", "kind", "state");
+
+CREATE TABLE sightings_new (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"email_id" INTEGER NOT NULL REFERENCES emails(id),
+	"source_id" INTEGER NOT NULL REFERENCES sources(id),
+	"run_id" INTEGER NOT NULL DEFAULT 0,
+	"location" TEXT NOT NULL DEFAULT '',
+	"query" TEXT NOT NULL,
+	"first_seen" DATETIME NOT NULL,
+	"last_seen" DATETIME NOT NULL,
+	UNIQUE ("email_id", "source_id", "run_id", "location")
+);
+
+INSERT INTO sightings_new (id, email_id, source_id, run_id, location, query, first_seen, last_seen)
+SELECT id, email_id, source_id, run_id, COALESCE((SELECT location FROM crawl_runs WHERE crawl_runs.id = sightings.run_id), ''),
+	query, first_seen, last_seen
+FROM sightings;
+
+DROP TABLE sightings;
+ALTER TABLE sightings_new RENAME TO sightings;
+
+CREATE INDEX sightings_run ON sightings ("run_id");
+CREATE INDEX sightings_location ON sightings ("location");
@@ -0,0 +1,7 @@
+-- Runs store the query templates they searched instead of keyword phrases.
+-- A keyword phrase was searched followed by the location.
+ALTER TABLE crawl_runs RENAME COLUMN "keywords" TO "query_templates";
+
+UPDATE crawl_runs
+SET query_templates = REPLACE(query_templates, char(10), ' {location}' || char(10)) || ' {location}'
+WHERE query_templates <> '';
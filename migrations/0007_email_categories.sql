@@ -0,0 +1,3 @@
+-- Category of the email on the page it was seen on, such as recruiting or
+-- noreply. Empty for sightings saved before emails were classified.
+ALTER TABLE sightings ADD COLUMN "category" TEXT NOT NULL DEFAULT '';
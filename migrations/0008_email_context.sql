@@ -0,0 +1,6 @@
+-- What the page says about an email: the page and job title are kept per
+-- source, the text and heading around the address per sighting.
+ALTER TABLE sources ADD COLUMN "title" TEXT NOT NULL DEFAULT '';
+ALTER TABLE sources ADD COLUMN "job_title" TEXT NOT NULL DEFAULT '';
+ALTER TABLE sightings ADD COLUMN "snippet" TEXT NOT NULL DEFAULT '';
+ALTER TABLE sightings ADD COLUMN "heading" TEXT NOT NULL DEFAULT '';
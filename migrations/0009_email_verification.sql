@@ -0,0 +1,3 @@
+-- Verification status of each address from its syntax and DNS records.
+-- Addresses saved before verification are left empty, read as unknown.
+ALTER TABLE emails ADD COLUMN "verification" TEXT NOT NULL DEFAULT '';
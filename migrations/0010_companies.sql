@@ -0,0 +1,14 @@
+-- Companies are identified by the registrable domain of their emails, or of
+-- the site that published a free mail address. Each sighting records the
+-- company its email belongs to; older sightings are assigned when loaded.
+CREATE TABLE companies (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"domain" TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	"name" TEXT NOT NULL,
+	"first_seen" DATETIME NOT NULL,
+	"last_seen" DATETIME NOT NULL
+);
+
+ALTER TABLE sightings ADD COLUMN "company_id" INTEGER REFERENCES companies(id);
+
+CREATE INDEX sightings_company ON sightings ("company_id");
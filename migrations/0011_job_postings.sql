@@ -0,0 +1,20 @@
+-- schema.org JobPosting objects found on pages, linked to the page and the
+-- hiring company. A posting is identified by its page, title and location.
+CREATE TABLE job_postings (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"source_id" INTEGER NOT NULL REFERENCES sources(id),
+	"company_id" INTEGER NOT NULL REFERENCES companies(id),
+	"run_id" INTEGER NOT NULL DEFAULT 0,
+	"title" TEXT NOT NULL,
+	"organization" TEXT NOT NULL DEFAULT '',
+	"location" TEXT NOT NULL DEFAULT '',
+	"date_posted" TEXT NOT NULL DEFAULT '',
+	"employment_type" TEXT NOT NULL DEFAULT '',
+	"contact_email" TEXT NOT NULL DEFAULT '',
+	"url" TEXT NOT NULL DEFAULT '',
+	"first_seen" DATETIME NOT NULL,
+	"last_seen" DATETIME NOT NULL,
+	UNIQUE ("source_id", "title", "location")
+);
+
+CREATE INDEX job_postings_company ON job_postings ("company_id");
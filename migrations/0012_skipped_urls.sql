@@ -0,0 +1,10 @@
+-- URLs a run did not fetch because of the host's robots.txt, for the
+-- run's report of skipped pages.
+CREATE TABLE skipped_urls (
+	"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	"run_id" INTEGER NOT NULL REFERENCES crawl_runs(id),
+	"url" TEXT NOT NULL,
+	"reason" TEXT NOT NULL,
+	"skipped_at" DATETIME NOT NULL,
+	UNIQUE ("run_id", "url")
+);
@@ -0,0 +1,144 @@
+package careerfind
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gocolly/colly"
+)
+
+// pageContext is what a page says about the emails found on it
+type pageContext struct {
+	Title    string
+	JobTitle string
+	// Snippets maps each email to the text around its first occurrence
+	Snippets map[string]string
+	// Headings maps each email to the nearest heading above it
+	Headings map[string]string
+}
+
+// Elements walked in document order to find the heading above each email.
+// Only elements that hold text directly are included, so an email is
+// attributed to its own paragraph rather than to an enclosing container.
+const contextSelector = "h1, h2, h3, h4, h5, h6, p, li, td, th, dd, dt, address, blockquote, a[href^='mailto:'], a[href*='/cdn-cgi/l/email-protection#']"
+
+// Job titles usually name a role, a seniority or a German gender marker
+var jobTitleRegex = regexp.MustCompile(`(?i)\b(engineer|developer|programmer|architect|manager|designer|analyst|scientist|consultant|specialist|administrator|coordinator|assistant|accountant|recruiter|intern(ship)?|trainee|apprentice|werkstudent|director|lead|head of|officer|representative|technician|nurse|teacher|sales|marketing|(senior|junior|staff|principal) \w+)\b|\((m|f|w|d)/(m|f|w|d)(/(m|f|w|d))?\)`)
+
+// extractPageContext reads the title, a likely job title, and the text and
+// heading around each email from a page. text is the page text with
+// obfuscated addresses rewritten by deobfuscateText.
+func extractPageContext(e *colly.HTMLElement, text string, emails []string) pageContext {
+	ctx := pageContext{
+		Title:    collapseSpace(e.ChildText("head > title")),
+		Snippets: make(map[string]string),
+		Headings: make(map[string]string),
+	}
+	for _, email := range emails {
+		ctx.Snippets[email] = textAround(text, email, contextRadius)
+	}
+
+	var heading string
+	var headings []string
+	located := make(map[string]bool)
+	e.ForEach(contextSelector, func(_ int, el *colly.HTMLElement) {
+		text := collapseSpace(el.Text)
+		if isHeading(el.Name) {
+			heading = text
+			headings = append(headings, text)
+			return
+		}
+
+		mentioned := elementEmails(el)
+		for _, email := range emails {
+			if located[email] || !containsFold(mentioned, email) {
+				continue
+			}
+			located[email] = true
+			if heading != "" {
+				ctx.Headings[email] = heading
+			}
+			// Addresses only in a mailto: link are described by its text
+			if ctx.Snippets[email] == "" {
+				ctx.Snippets[email] = text
+			}
+		}
+	})
+
+	ctx.JobTitle = findJobTitle(headings, ctx.Title)
+	return ctx
+}
+
+// findJobTitle returns the first heading, or else the page title, that reads
+// like a job title.
+func findJobTitle(headings []string, title string) string {
+	for _, candidate := range append(headings, title) {
+		// Long headings are sentences, not titles
+		if len(candidate) > 0 && len(candidate) <= 120 && jobTitleRegex.MatchString(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// elementEmails returns the addresses an element shows or links to, in
+// plain, obfuscated or Cloudflare-protected form.
+func elementEmails(el *colly.HTMLElement) []string {
+	emails := emailRegex.FindAllString(deobfuscateText(el.Text), -1)
+	if email := mailtoAddress(el.Attr("href")); email != "" {
+		emails = append(emails, email)
+	}
+	if email := cloudflareLinkEmail(el.Attr("href")); email != "" {
+		emails = append(emails, email)
+	}
+	for _, encoded := range el.ChildAttrs("[data-cfemail]", "data-cfemail") {
+		emails = append(emails, decodeCloudflareEmail(encoded))
+	}
+	return emails
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHeading(name string) bool {
+	return len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6'
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// textAround returns up to radius bytes of text on each side of the first
+// occurrence of s, with whitespace collapsed. It is empty if s is not found.
+func textAround(text string, s string, radius int) string {
+	i := strings.Index(text, s)
+	if i < 0 {
+		return ""
+	}
+
+	start, end := i-radius, i+len(s)+radius
+	if start < 0 {
+		start = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	// Do not cut a UTF-8 sequence in half
+	for start > 0 && !isRuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !isRuneStart(text[end]) {
+		end++
+	}
+	return collapseSpace(text[start:end])
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
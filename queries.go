@@ -0,0 +1,106 @@
+package careerfind
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Query templates are search phrases with {name} placeholders. {location}
+// is replaced by each searched location, and a template without it searches
+// the same query everywhere; any other placeholder takes every value listed
+// for it under "query_values" in the config, so a template is expanded into
+// the cross product of its placeholders' values.
+var defaultQueryTemplates = []string{"email careers {location}"}
+
+var placeholderRegex = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+// locationPlaceholder is filled from the run's locations, not query_values
+const locationPlaceholder = "location"
+
+// queryTemplates returns templates, or the configured or built-in default
+// templates when none are given.
+func (cfg Config) queryTemplates(templates []string) []string {
+	if len(templates) > 0 {
+		return templates
+	}
+	if len(cfg.QueryTemplates) > 0 {
+		return cfg.QueryTemplates
+	}
+	return defaultQueryTemplates
+}
+
+// templatePlaceholders returns the distinct placeholder names in template
+// in the order they appear.
+func templatePlaceholders(template string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderRegex.FindAllStringSubmatch(template, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// validateQueryTemplates checks that every placeholder of templates other
+// than {location} has values.
+func validateQueryTemplates(templates []string, values map[string][]string) []string {
+	var problems []string
+	for _, template := range templates {
+		for _, name := range templatePlaceholders(template) {
+			if name != locationPlaceholder && len(values[name]) == 0 {
+				problems = append(problems, fmt.Sprintf("%q uses {%s} but query_values has no %q values", template, name, name))
+			}
+		}
+	}
+	return problems
+}
+
+// CheckQueryTemplates returns an error if templates, such as those given
+// in a Query, use a placeholder that cfg has no query_values for.
+func (cfg Config) CheckQueryTemplates(templates []string) error {
+	if problems := validateQueryTemplates(templates, cfg.QueryValues); len(problems) > 0 {
+		return fmt.Errorf("invalid query template: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// expandQueryTemplates returns the distinct search queries for location
+// from every template, in template order.
+func expandQueryTemplates(templates []string, values map[string][]string, location string) ([]string, error) {
+	var queries []string
+	seen := make(map[string]bool)
+
+	for _, template := range templates {
+		expanded := []string{template}
+		for _, name := range templatePlaceholders(template) {
+			replacements := values[name]
+			if name == locationPlaceholder {
+				replacements = []string{location}
+			}
+			if len(replacements) == 0 {
+				return nil, fmt.Errorf("query template %q: no values for {%s}", template, name)
+			}
+
+			var next []string
+			for _, partial := range expanded {
+				for _, value := range replacements {
+					next = append(next, strings.ReplaceAll(partial, "{"+name+"}", value))
+				}
+			}
+			expanded = next
+		}
+
+		for _, query := range expanded {
+			query = strings.Join(strings.Fields(query), " ")
+			if query != "" && !seen[query] {
+				seen[query] = true
+				queries = append(queries, query)
+			}
+		}
+	}
+
+	return queries, nil
+}
@@ -0,0 +1,380 @@
+package careerfind
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+// robots.txt is cached per host for a day, as RFC 9309 recommends. A host
+// whose robots.txt could not be fetched is asked again sooner.
+const (
+	robotsCacheTTL = 24 * time.Hour
+	robotsRetryTTL = time.Hour
+)
+
+// maxCrawlDelay caps the Crawl-delay honored for a host, so one site
+// cannot stall a run
+const maxCrawlDelay = time.Minute
+
+// Reasons robots.txt skips a URL; domain filters use skipDomainFilter
+const (
+	skipDisallowed = "disallowed by robots.txt"
+	skipNoRobots   = "robots.txt unreachable"
+)
+
+// robotsRules are the robots.txt rules of one host that apply to us
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	// unreachable is set when robots.txt could not be fetched, in which case
+	// nothing may be crawled
+	unreachable bool
+}
+
+// robotsRule is an Allow or Disallow line. Patterns may use * for any
+// characters and end in $ to match the end of the path.
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsAgent is the product token robots.txt groups are matched against.
+// It is fixed rather than taken from user_agent, which is usually a
+// browser's, so sites can address careerfind whatever it sends.
+const robotsAgent = "careerfind"
+
+// parseRobotsTxt returns the rules of the groups for agent, or of the *
+// group if none names it. Groups naming the same agent are merged.
+func parseRobotsTxt(data string, agent string) robotsRules {
+	var named, wildcard robotsRules
+	foundNamed := false
+
+	var agents []string
+	inRules := false
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			// A user-agent line after rules starts a new group
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+			continue
+		}
+		if key != "allow" && key != "disallow" && key != "crawl-delay" {
+			continue
+		}
+		inRules = true
+
+		var targets []*robotsRules
+		for _, a := range agents {
+			switch {
+			case a == "*":
+				targets = append(targets, &wildcard)
+			case a == agent:
+				targets = append(targets, &named)
+				foundNamed = true
+			}
+		}
+		for _, rules := range targets {
+			rules.add(key, value)
+		}
+	}
+
+	if foundNamed {
+		return named
+	}
+	return wildcard
+}
+
+func (r *robotsRules) add(key string, value string) {
+	switch key {
+	case "crawl-delay":
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			r.crawlDelay = time.Duration(seconds * float64(time.Second))
+		}
+	case "allow", "disallow":
+		// An empty Disallow allows everything
+		if value != "" {
+			r.rules = append(r.rules, robotsRule{pattern: value, allow: key == "allow"})
+		}
+	}
+}
+
+// robotsFromResponse returns the rules for a robots.txt response. A missing
+// robots.txt allows everything; a server error or no response at all
+// disallows everything.
+func robotsFromResponse(status int, body string, agent string) robotsRules {
+	switch {
+	case status >= 200 && status < 300:
+		return parseRobotsTxt(body, agent)
+	case status >= 400 && status < 500:
+		return robotsRules{}
+	default:
+		return robotsRules{unreachable: true}
+	}
+}
+
+// allowed reports whether path, including any query, may be crawled. The
+// longest matching rule decides, with Allow winning ties.
+func (r robotsRules) allowed(path string) bool {
+	if r.unreachable {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if !robotsPatternMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allow, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allow
+}
+
+// robotsPatternMatch reports whether a robots.txt path pattern matches
+// the start of path, or all of it for patterns ending in $.
+func robotsPatternMatch(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+	return !anchored || rest == ""
+}
+
+// hostRobots is the cached robots.txt of one host
+type hostRobots struct {
+	// ready is closed once rules are fetched
+	ready     chan struct{}
+	rules     robotsRules
+	fetchedAt time.Time
+
+	// next is the earliest time the host may be requested again
+	mu   sync.Mutex
+	next time.Time
+}
+
+// robotsCache fetches each host's robots.txt once and spaces requests to
+// the host by its Crawl-delay
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*hostRobots
+	// fetch returns the status and body of a robots.txt URL
+	fetch func(robotsURL string, proxyEnabled bool) (int, string)
+}
+
+// newRobotsCache returns a cache for a crawler. It is shared by all of the
+// crawler's runs, so a host is asked once a day.
+func newRobotsCache(fetch func(robotsURL string, proxyEnabled bool) (int, string)) *robotsCache {
+	return &robotsCache{hosts: make(map[string]*hostRobots), fetch: fetch}
+}
+
+// host returns the rules for the host of u, fetching robots.txt if they are
+// not cached. Concurrent callers wait for a single fetch.
+func (c *robotsCache) host(u *url.URL, proxyEnabled bool) *hostRobots {
+	key := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	h, ok := c.hosts[key]
+	if ok {
+		select {
+		case <-h.ready:
+			ttl := robotsCacheTTL
+			if h.rules.unreachable {
+				ttl = robotsRetryTTL
+			}
+			ok = time.Since(h.fetchedAt) <= ttl
+		default:
+		}
+	}
+	if !ok {
+		h = &hostRobots{ready: make(chan struct{})}
+		c.hosts[key] = h
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		status, body := c.fetch(key+"/robots.txt", proxyEnabled)
+		h.rules = robotsFromResponse(status, body, robotsAgent)
+		h.fetchedAt = time.Now()
+		close(h.ready)
+	}
+	<-h.ready
+	return h
+}
+
+// check reports whether u may be crawled and, if not, why
+func (c *robotsCache) check(u *url.URL, proxyEnabled bool) (bool, string) {
+	h := c.host(u, proxyEnabled)
+	if h.rules.unreachable {
+		return false, skipNoRobots
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !h.rules.allowed(path) {
+		return false, skipDisallowed
+	}
+	return true, ""
+}
+
+// wait blocks until the host of u may be requested again under its
+// Crawl-delay, or ctx is done.
+func (c *robotsCache) wait(ctx context.Context, u *url.URL, proxyEnabled bool) {
+	h := c.host(u, proxyEnabled)
+	delay := h.rules.crawlDelay
+	if delay == 0 {
+		return
+	}
+	if delay > maxCrawlDelay {
+		delay = maxCrawlDelay
+	}
+
+	h.mu.Lock()
+	start := time.Now()
+	if h.next.After(start) {
+		start = h.next
+	}
+	h.next = start.Add(delay)
+	h.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// fetchRobotsTxt downloads a robots.txt with the crawler's user agent and
+// proxy. The status is 0 if no response was received.
+func (cr *Crawler) fetchRobotsTxt(robotsURL string, proxyEnabled bool) (int, string) {
+	c, err := cr.newCollector(proxyEnabled, false)
+	if err != nil {
+		return 0, ""
+	}
+
+	var status int
+	var body string
+	c.OnResponse(func(r *colly.Response) {
+		status, body = r.StatusCode, string(r.Body)
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		status = r.StatusCode
+	})
+	if err := c.Visit(robotsURL); err != nil && status == 0 {
+		cr.logger.Printf("Failed to fetch %s: %v", robotsURL, err)
+	}
+	return status, body
+}
+
+// SkippedURL is a URL the crawler did not fetch, and why
+type SkippedURL struct {
+	RunID  int64
+	URL    string
+	Reason string
+}
+
+// saveSkippedURLs records the URLs a run skipped
+func (cr *Crawler) saveSkippedURLs(run *crawlRun, skipped []SkippedURL) error {
+	for _, s := range skipped {
+		if _, err := cr.db.Exec(`INSERT OR IGNORE INTO skipped_urls (run_id, url, reason, skipped_at) VALUES (?, ?, ?, ?)`,
+			run.ID, s.URL, s.Reason, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to record skipped URL %s: %w", s.URL, err)
+		}
+	}
+	return nil
+}
+
+// SkippedURLs returns the URLs a run skipped, in the order it met them. A
+// runID of 0 means the run this Crawler last started or resumed, or else
+// the latest run in the database.
+func (cr *Crawler) SkippedURLs(runID int64) ([]SkippedURL, error) {
+	if runID == 0 {
+		runID = cr.lastRun.Load()
+	}
+	if runID == 0 {
+		if err := cr.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM crawl_runs`).Scan(&runID); err != nil {
+			return nil, fmt.Errorf("failed to look up the latest run: %w", err)
+		}
+	}
+
+	rows, err := cr.db.Query(`SELECT run_id, url, reason FROM skipped_urls WHERE run_id = ? ORDER BY id`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load skipped URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var skipped []SkippedURL
+	for rows.Next() {
+		var s SkippedURL
+		if err := rows.Scan(&s.RunID, &s.URL, &s.Reason); err != nil {
+			return nil, fmt.Errorf("failed to read skipped URL: %w", err)
+		}
+		skipped = append(skipped, s)
+	}
+	return skipped, rows.Err()
+}
+
+// reportSkippedURLs logs the URLs a run skipped and a summary per reason
+func (cr *Crawler) reportSkippedURLs(run *crawlRun) {
+	skipped, err := cr.SkippedURLs(run.ID)
+	if err != nil {
+		cr.logger.Printf("Failed to report skipped URLs: %v", err)
+		return
+	}
+	if len(skipped) == 0 {
+		return
+	}
+
+	var reasons []string
+	counts := make(map[string]int)
+	for _, s := range skipped {
+		cr.logger.Printf("Run %d skipped %s: %s", run.ID, s.URL, s.Reason)
+		if counts[s.Reason] == 0 {
+			reasons = append(reasons, s.Reason)
+		}
+		counts[s.Reason]++
+	}
+	for _, reason := range reasons {
+		cr.logger.Printf("Run %d skipped %d URL(s): %s", run.ID, counts[reason], reason)
+	}
+}
@@ -0,0 +1,326 @@
+package careerfind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// SearchProfile is a named search run on its own cron schedule by
+// `careerfind schedule`. Profiles are listed under "profiles" in config.json.
+type SearchProfile struct {
+	Name string `json:"name"`
+	// Schedule is a standard five-field cron expression or a descriptor
+	// such as @daily or @every 6h.
+	Schedule  string   `json:"schedule"`
+	Locations []string `json:"locations"`
+	// Engines are registered search engine names; empty means all.
+	Engines []string `json:"engines"`
+	// QueryTemplates override the configured query templates
+	QueryTemplates []string `json:"query_templates"`
+	LinkedIn       bool     `json:"linkedin"`
+	Proxy          bool     `json:"proxy"`
+	// OutputFormat lists the outputs, comma-separated; see parseOutputs
+	OutputFormat string `json:"output_format"`
+	// GroupBy groups output files by "location" (the default) or "company"
+	GroupBy string `json:"group_by"`
+	// Only limits output and notifications to these email categories;
+	// empty means only_categories from the config.
+	Only []string `json:"only"`
+	// Notify lists notification targets: "telegram" for the configured chat,
+	// "telegram:<chat id>" for another chat, or "none".
+	Notify []string `json:"notify"`
+}
+
+// defaultProfile is scheduled when the config defines no profiles
+var defaultProfile = SearchProfile{
+	Name:         "default",
+	Schedule:     "@daily",
+	Locations:    []string{"worldwide"},
+	Engines:      []string{"google", "bing"},
+	Proxy:        true,
+	OutputFormat: "json",
+	Notify:       []string{"telegram"},
+}
+
+// SearchProfiles returns the configured profiles, or the default profile
+func (cfg Config) SearchProfiles() []SearchProfile {
+	if len(cfg.Profiles) == 0 {
+		return []SearchProfile{defaultProfile}
+	}
+	return cfg.Profiles
+}
+
+// engineSpec returns the profile's engines in -b syntax
+func (p SearchProfile) engineSpec() string {
+	if len(p.Engines) == 0 {
+		return "all"
+	}
+	return strings.Join(p.Engines, ",")
+}
+
+func (p SearchProfile) outputFormat() string {
+	if p.OutputFormat == "" {
+		return "json"
+	}
+	return p.OutputFormat
+}
+
+func (p SearchProfile) groupBy() string {
+	if p.GroupBy == "" {
+		return groupByLocationName
+	}
+	return p.GroupBy
+}
+
+// validate returns a description of each problem with the profile, whose
+// query templates are filled from queryValues
+func (p SearchProfile) validate(queryValues map[string][]string) []string {
+	name := p.Name
+	if name == "" {
+		name = "(unnamed)"
+	}
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("profile %s: ", name)+fmt.Sprintf(format, args...))
+	}
+
+	if p.Name == "" {
+		add("name cannot be empty")
+	}
+	if _, err := cron.ParseStandard(p.Schedule); err != nil {
+		add("invalid schedule %q: %v", p.Schedule, err)
+	}
+	if len(p.Locations) == 0 {
+		add("no locations")
+	}
+	for _, location := range p.Locations {
+		if strings.TrimSpace(location) == "" {
+			add("location cannot be empty")
+			break
+		}
+	}
+	if _, err := resolveSearchEngines(p.engineSpec()); err != nil {
+		add("%v", err)
+	}
+	for _, problem := range validateQueryTemplates(p.QueryTemplates, queryValues) {
+		add("query_templates: %s", problem)
+	}
+	if _, err := parseOutputs(p.outputFormat()); err != nil {
+		add("%v", err)
+	}
+	if !containsString(resultGroupings, p.groupBy()) {
+		add("invalid group_by %q: want location or company", p.GroupBy)
+	}
+	for _, target := range p.Notify {
+		if _, err := parseNotifyTarget(target); err != nil {
+			add("%v", err)
+		}
+	}
+	if _, err := ParseCategories(strings.Join(p.Only, ",")); err != nil {
+		add("%v", err)
+	}
+	return problems
+}
+
+// validateProfiles checks every configured profile and that names are unique
+func validateProfiles(profiles []SearchProfile, queryValues map[string][]string) []string {
+	var problems []string
+	seen := make(map[string]bool)
+	for _, p := range profiles {
+		problems = append(problems, p.validate(queryValues)...)
+		if p.Name != "" && seen[p.Name] {
+			problems = append(problems, fmt.Sprintf("profile %s: defined more than once", p.Name))
+		}
+		seen[p.Name] = true
+	}
+	return problems
+}
+
+// notifyTarget is a parsed entry of SearchProfile.Notify. An empty ChatID
+// means the configured telegram_chat_id.
+type notifyTarget struct {
+	Method string
+	ChatID string
+}
+
+func parseNotifyTarget(target string) (notifyTarget, error) {
+	method, chatID, _ := strings.Cut(strings.TrimSpace(target), ":")
+	switch method {
+	case "none":
+		if chatID == "" {
+			return notifyTarget{Method: method}, nil
+		}
+	case "telegram":
+		return notifyTarget{Method: method, ChatID: chatID}, nil
+	}
+	return notifyTarget{}, fmt.Errorf("invalid notification target %q", target)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Schedule runs each search profile on its schedule until ctx is
+// cancelled, then waits for a search in progress to stop before returning.
+func (cr *Crawler) Schedule(ctx context.Context, profiles []SearchProfile) error {
+	c := cron.New(cron.WithChain(cron.Recover(cron.PrintfLogger(cr.logger))))
+
+	var jobs []*profileJob
+	for _, profile := range profiles {
+		job, err := cr.scheduleProfile(ctx, c, profile)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	c.Start()
+	for _, job := range jobs {
+		next := c.Entry(job.id).Next.Format(time.RFC3339)
+		cr.logger.Printf("Profile %s scheduled (%s), next search at %s", job.profile.Name, job.profile.Schedule, next)
+	}
+
+	<-ctx.Done()
+
+	cr.logger.Printf("Shutting down scheduler")
+	<-c.Stop().Done()
+	cr.logger.Printf("Scheduler stopped")
+	return nil
+}
+
+// profileJob is the cron job running one search profile
+type profileJob struct {
+	crawler *Crawler
+	ctx     context.Context
+	cron    *cron.Cron
+	id      cron.EntryID
+	profile SearchProfile
+	// running is held while the profile's search is in progress
+	running sync.Mutex
+}
+
+func (cr *Crawler) scheduleProfile(ctx context.Context, c *cron.Cron, profile SearchProfile) (*profileJob, error) {
+	job := &profileJob{crawler: cr, ctx: ctx, cron: c, profile: profile}
+	id, err := c.AddJob(profile.Schedule, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule profile %s: %w", profile.Name, err)
+	}
+	job.id = id
+	return job, nil
+}
+
+func (j *profileJob) Run() {
+	name := j.profile.Name
+	logger := j.crawler.logger
+
+	// A profile still running when it is next due is not started twice;
+	// other profiles wait their turn.
+	if !j.running.TryLock() {
+		logger.Printf("Profile %s is still running, skipping this search", name)
+		return
+	}
+	defer j.running.Unlock()
+
+	j.crawler.searchMu.Lock()
+	defer j.crawler.searchMu.Unlock()
+
+	if j.ctx.Err() != nil {
+		return
+	}
+
+	logger.Printf("Profile %s: automated search started", name)
+	if err := j.crawler.runProfile(j.ctx, j.profile); err != nil {
+		logger.Printf("Profile %s: automated search failed: %v", name, err)
+	} else {
+		logger.Printf("Profile %s: automated search completed", name)
+	}
+	if j.ctx.Err() == nil {
+		logger.Printf("Profile %s: next search at %s", name, j.cron.Entry(j.id).Next.Format(time.RFC3339))
+	}
+}
+
+// runProfile crawls all locations of a profile in one run, writing the
+// results to its outputs and notification targets.
+func (cr *Crawler) runProfile(ctx context.Context, profile SearchProfile) error {
+	sink, err := cr.profileSink(profile)
+	if err != nil {
+		return err
+	}
+	if err := sink.Open(); err != nil {
+		return fmt.Errorf("failed to open outputs: %w", err)
+	}
+
+	var failures []string
+	w := &sinkWriter{crawler: cr, sink: sink}
+	if err := cr.crawlProfile(ctx, profile, w.write); err != nil {
+		if ctx.Err() != nil {
+			sink.Close()
+			return err
+		}
+		failures = append(failures, err.Error())
+	}
+	if w.err != nil {
+		failures = append(failures, w.err.Error())
+	}
+	if err := sink.Close(); err != nil {
+		failures = append(failures, fmt.Sprintf("failed to save results: %v", err))
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// profileSink returns the outputs and notification targets of a profile.
+// Notifications are best effort, as for `careerfind run`.
+func (cr *Crawler) profileSink(profile SearchProfile) (Sink, error) {
+	outputs, err := cr.Outputs(profile.outputFormat(), profile.groupBy())
+	if err != nil {
+		return nil, err
+	}
+	sinks := []Sink{outputs}
+	for _, target := range profile.Notify {
+		sink, err := cr.Notification(target)
+		if err != nil {
+			return nil, err
+		}
+		if sink != nil {
+			sinks = append(sinks, sink)
+		}
+	}
+	return FanOut(sinks...), nil
+}
+
+// crawlProfile runs one crawl of the profile's search, passing each result
+// in its categories to emit. Requests are logged if the Crawler is verbose.
+func (cr *Crawler) crawlProfile(ctx context.Context, profile SearchProfile, emit func(Result)) error {
+	locations, err := LoadLocations(profile.Locations)
+	if err != nil {
+		return err
+	}
+
+	only := cr.config.OnlyCategories
+	if len(profile.Only) > 0 {
+		only = profile.Only
+	}
+	return cr.crawl(ctx, Query{
+		Locations: locations,
+		Engines:   profile.engineSpec(),
+		Templates: profile.QueryTemplates,
+		LinkedIn:  profile.LinkedIn,
+		Proxy:     profile.Proxy,
+		Only:      only,
+	}, emit)
+}
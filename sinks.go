@@ -0,0 +1,391 @@
+package careerfind
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives the results of a crawl as they are found. Open is called
+// before the first Write and Close after the last. Writes are never
+// concurrent.
+type Sink interface {
+	Open() error
+	Write(result Result) error
+	Close() error
+}
+
+// OutputFormats are the outputs accepted by Outputs and a profile's
+// output_format
+var OutputFormats = []string{"json", "jsonl", "csv", "txt", "xlsx", "sqlite", "webhook"}
+
+// outputAliases are accepted in place of an output name
+var outputAliases = map[string]string{
+	"ndjson": "jsonl",
+}
+
+// parseOutputs turns a comma-separated -o value into output names
+func parseOutputs(spec string) ([]string, error) {
+	var outputs []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if alias, ok := outputAliases[name]; ok {
+			name = alias
+		}
+		if name == "" || containsString(outputs, name) {
+			continue
+		}
+		if !containsString(OutputFormats, name) {
+			return nil, fmt.Errorf("unsupported output format %q (available: %s)", name, strings.Join(OutputFormats, ","))
+		}
+		outputs = append(outputs, name)
+	}
+	if len(outputs) == 0 {
+		return nil, errors.New("no output format given")
+	}
+	return outputs, nil
+}
+
+// Outputs returns a Sink writing to each of the outputs in spec, a
+// comma-separated list of OutputFormats. File outputs are grouped by
+// "location" or "company".
+func (cr *Crawler) Outputs(spec string, group string) (Sink, error) {
+	outputs, err := parseOutputs(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateGrouping(group); err != nil {
+		return nil, err
+	}
+	sinks := make([]Sink, len(outputs))
+	for i, name := range outputs {
+		sinks[i] = cr.outputSink(name, group)
+	}
+	return FanOut(sinks...), nil
+}
+
+// outputSink returns the sink for an output name from parseOutputs. File
+// outputs are grouped by location or company.
+func (cr *Crawler) outputSink(name string, group string) Sink {
+	switch name {
+	case "jsonl":
+		return &jsonlSink{crawler: cr}
+	case "sqlite":
+		return &sqliteSink{crawler: cr}
+	case "webhook":
+		return &webhookSink{url: cr.config.WebhookURL, client: &http.Client{Timeout: time.Duration(cr.config.RequestTimeout) * time.Second}}
+	default:
+		return &fileSink{crawler: cr, format: name, group: group}
+	}
+}
+
+// FanOut returns a Sink writing every result to each of sinks. A sink that
+// fails does not stop the others; their errors are returned together.
+func FanOut(sinks ...Sink) Sink {
+	return fanOut(sinks)
+}
+
+type fanOut []Sink
+
+func (f fanOut) Open() error {
+	for i, sink := range f {
+		if err := sink.Open(); err != nil {
+			// Close the sinks already opened
+			fanOut(f[:i]).Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fanOut) Write(result Result) error {
+	var errs []string
+	for _, sink := range f {
+		if err := sink.Write(result); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+func (f fanOut) Close() error {
+	var errs []string
+	for _, sink := range f {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+func joinErrors(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
+
+// sinkWriter passes streamed results to a sink one at a time. It keeps the
+// first write error and logs the rest, so an output that is down does not
+// stop the crawl.
+type sinkWriter struct {
+	crawler *Crawler
+	sink    Sink
+
+	mu  sync.Mutex
+	err error
+}
+
+func (w *sinkWriter) write(result Result) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.sink.Write(result); err != nil {
+		w.crawler.logger.Printf("Failed to write result from %s: %v", result.Source, err)
+		if w.err == nil {
+			w.err = fmt.Errorf("failed to write results: %w", err)
+		}
+	}
+}
+
+// Crawl is like Run but writes each result to sink as soon as its page has
+// been crawled. The sink is opened first and closed when the crawl ends.
+func (cr *Crawler) Crawl(ctx context.Context, q Query, sink Sink) error {
+	return cr.writeTo(sink, func(emit func(Result)) error {
+		return cr.crawl(ctx, q, emit)
+	})
+}
+
+// writeTo opens sink, writes to it the results crawl emits and closes it.
+// Errors of the sink are returned ahead of ErrSomePagesFailed.
+func (cr *Crawler) writeTo(sink Sink, crawl func(emit func(Result)) error) error {
+	if err := sink.Open(); err != nil {
+		return fmt.Errorf("failed to open outputs: %w", err)
+	}
+	w := &sinkWriter{crawler: cr, sink: sink}
+	err := crawl(w.write)
+	closeErr := sink.Close()
+	if err != nil && !errors.Is(err, ErrSomePagesFailed) {
+		return err
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to save results: %w", closeErr)
+	}
+	return err
+}
+
+// fileSink collects results and writes them to a new file in output_dir
+// when closed, since the json, csv, txt and xlsx files are sorted and
+// grouped.
+type fileSink struct {
+	crawler *Crawler
+	format  string
+	group   string
+	batch   []Result
+}
+
+func (s *fileSink) Open() error {
+	return nil
+}
+
+func (s *fileSink) Write(result Result) error {
+	s.batch = append(s.batch, result)
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	// A crawl that found nothing leaves no empty file behind
+	if len(s.batch) == 0 {
+		s.crawler.logger.Printf("No results to save as %s", s.format)
+		return nil
+	}
+	return s.crawler.saveResults(s.format, s.group, s.batch)
+}
+
+// sqliteSink writes each email to a new SQLite database in output_dir as it
+// is found, one row per email with the columns of the CSV output. Unlike
+// careerfind.db it holds only this run's results and can be shared.
+type sqliteSink struct {
+	crawler *Crawler
+	db      *sql.DB
+	insert  *sql.Stmt
+}
+
+func (s *sqliteSink) Open() error {
+	filename, err := s.crawler.resultsFilename("sqlite")
+	if err != nil {
+		return err
+	}
+	db, err := openDB(filename)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, len(emailColumns))
+	for i, column := range emailColumns {
+		columns[i] = strings.ToLower(strings.ReplaceAll(column, " ", "_"))
+	}
+	if _, err := db.Exec(fmt.Sprintf(`CREATE TABLE emails (%s TEXT)`, strings.Join(columns, " TEXT, "))); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create results table: %w", err)
+	}
+	insert, err := db.Prepare(fmt.Sprintf(`INSERT INTO emails (%s) VALUES (?%s)`, strings.Join(columns, ", "), strings.Repeat(", ?", len(columns)-1)))
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to prepare results insert: %w", err)
+	}
+	s.db, s.insert = db, insert
+	return nil
+}
+
+func (s *sqliteSink) Write(result Result) error {
+	for _, email := range result.Emails {
+		row := emailRow(result, email)
+		args := make([]interface{}, len(row))
+		for i, field := range row {
+			args[i] = field
+		}
+		if _, err := s.insert.Exec(args...); err != nil {
+			return fmt.Errorf("failed to save %s to SQLite output: %w", email, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteSink) Close() error {
+	s.insert.Close()
+	return s.db.Close()
+}
+
+// webhookSink posts each result as JSON to webhook_url as it is found
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) Open() error {
+	if s.url == "" {
+		return errors.New("webhook output needs webhook_url in the config")
+	}
+	return nil
+}
+
+func (s *webhookSink) Write(result Result) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post result to webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	return nil
+}
+
+// validateWebhookURL returns a description of a problem with webhook_url
+func validateWebhookURL(rawURL string) []string {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return []string{fmt.Sprintf("webhook_url: %q is not an http or https URL", rawURL)}
+	}
+	return nil
+}
+
+// telegramSink sends all results to a Telegram chat in one message when
+// closed. An empty chatID means telegram_chat_id.
+type telegramSink struct {
+	crawler *Crawler
+	chatID  string
+	batch   []Result
+}
+
+func (s *telegramSink) Open() error {
+	return nil
+}
+
+func (s *telegramSink) Write(result Result) error {
+	s.batch = append(s.batch, result)
+	return nil
+}
+
+func (s *telegramSink) Close() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	return s.crawler.sendTelegramNotification(s.chatID, s.batch)
+}
+
+// Notification returns a Sink sending the results to a notification target:
+// "telegram" for telegram_chat_id, "telegram:<chat id>" for another chat,
+// or "none", for which it returns nil. Notifications are best effort; their
+// errors are logged rather than returned.
+func (cr *Crawler) Notification(target string) (Sink, error) {
+	t, err := parseNotifyTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	if sink := cr.notifySink(t); sink != nil {
+		return loggedSink{Sink: sink, logger: cr.logger, name: "Telegram notification"}, nil
+	}
+	return nil, nil
+}
+
+// notifySink returns the sink for a notification target, or nil for none
+func (cr *Crawler) notifySink(target notifyTarget) Sink {
+	if target.Method == "telegram" {
+		return &telegramSink{crawler: cr, chatID: target.ChatID}
+	}
+	return nil
+}
+
+// loggedSink logs the errors of a sink instead of returning them, for
+// best-effort notifications
+type loggedSink struct {
+	Sink
+	logger *log.Logger
+	name   string
+}
+
+func (s loggedSink) Open() error {
+	if err := s.Sink.Open(); err != nil {
+		s.logger.Printf("Failed to open %s: %v", s.name, err)
+	}
+	return nil
+}
+
+func (s loggedSink) Write(result Result) error {
+	if err := s.Sink.Write(result); err != nil {
+		s.logger.Printf("Failed to send %s: %v", s.name, err)
+	}
+	return nil
+}
+
+func (s loggedSink) Close() error {
+	if err := s.Sink.Close(); err != nil {
+		s.logger.Printf("Failed to send %s: %v", s.name, err)
+	}
+	return nil
+}
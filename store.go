@@ -0,0 +1,282 @@
+package careerfind
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// saveResultsToDB persists results as soon as a page is processed, so they
+// survive an interrupted run. Emails and pages seen before are updated in
+// place rather than duplicated.
+func (cr *Crawler) saveResultsToDB(run *crawlRun, batch []Result) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	tx, err := cr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, result := range batch {
+		if err := upsertResult(tx, run.ID, result); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit results: %w", err)
+	}
+	return nil
+}
+
+// upsertResult records one sighting per email of result
+func upsertResult(tx *sql.Tx, runID int64, result Result) error {
+	seen := result.Timestamp.UTC()
+
+	sourceID, err := upsertSource(tx, result.Source, result.PageTitle, result.JobTitle, seen)
+	if err != nil {
+		return err
+	}
+
+	for _, email := range result.Emails {
+		var emailID int64
+		// A failed lookup does not overwrite an earlier verification
+		if err := tx.QueryRow(`INSERT INTO emails (address, verification, first_seen, last_seen) VALUES (?, ?, ?, ?)
+			ON CONFLICT (address) DO UPDATE SET last_seen = MAX(last_seen, excluded.last_seen),
+				verification = CASE WHEN excluded.verification IN ('', ?) THEN verification ELSE excluded.verification END
+			RETURNING id`, email, result.Details[email].Verification, seen, seen, verifyUnknown).Scan(&emailID); err != nil {
+			return fmt.Errorf("failed to save email %s: %w", email, err)
+		}
+
+		companyID, err := upsertCompany(tx, result.Company(email), seen)
+		if err != nil {
+			return err
+		}
+
+		detail := result.Details[email]
+		if _, err := tx.Exec(`INSERT INTO sightings (email_id, source_id, company_id, run_id, location, query, category, snippet, heading, first_seen, last_seen)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (email_id, source_id, run_id, location) DO UPDATE SET last_seen = MAX(last_seen, excluded.last_seen),
+				company_id = excluded.company_id, category = excluded.category, snippet = excluded.snippet, heading = excluded.heading`,
+			emailID, sourceID, companyID, runID, result.Location, result.Query, result.Category(email), detail.Snippet, detail.Heading, seen, seen); err != nil {
+			return fmt.Errorf("failed to save sighting of %s: %w", email, err)
+		}
+	}
+
+	return nil
+}
+
+// upsertSource records a page, keeping titles already known when the page
+// no longer has them
+func upsertSource(tx *sql.Tx, url string, title string, jobTitle string, seen time.Time) (int64, error) {
+	var id int64
+	if err := tx.QueryRow(`INSERT INTO sources (url, title, job_title, first_seen, last_seen) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET last_seen = MAX(last_seen, excluded.last_seen),
+			title = COALESCE(NULLIF(excluded.title, ''), title),
+			job_title = COALESCE(NULLIF(excluded.job_title, ''), job_title)
+		RETURNING id`, url, title, jobTitle, seen, seen).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to save source %s: %w", url, err)
+	}
+	return id, nil
+}
+
+func upsertCompany(tx *sql.Tx, domain string, seen time.Time) (int64, error) {
+	var id int64
+	if err := tx.QueryRow(`INSERT INTO companies (domain, name, first_seen, last_seen) VALUES (?, ?, ?, ?)
+		ON CONFLICT (domain) DO UPDATE SET last_seen = MAX(last_seen, excluded.last_seen)
+		RETURNING id`, domain, companyName(domain), seen, seen).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to save company %s: %w", domain, err)
+	}
+	return id, nil
+}
+
+// saveJobPostingsToDB persists the job postings found on a page, updating
+// postings seen before
+func (cr *Crawler) saveJobPostingsToDB(run *crawlRun, postings []JobPosting) error {
+	if len(postings) == 0 {
+		return nil
+	}
+
+	tx, err := cr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, posting := range postings {
+		seen := posting.Timestamp.UTC()
+		sourceID, err := upsertSource(tx, posting.Source, "", "", seen)
+		if err != nil {
+			return err
+		}
+		companyID, err := upsertCompany(tx, posting.Company, seen)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`INSERT INTO job_postings (source_id, company_id, run_id, title, organization, location, date_posted, employment_type, contact_email, url, first_seen, last_seen)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (source_id, title, location) DO UPDATE SET last_seen = MAX(last_seen, excluded.last_seen),
+				company_id = excluded.company_id, run_id = excluded.run_id, organization = excluded.organization,
+				date_posted = excluded.date_posted, employment_type = excluded.employment_type,
+				contact_email = excluded.contact_email, url = excluded.url`,
+			sourceID, companyID, run.ID, posting.Title, posting.Organization, posting.Location, posting.DatePosted,
+			posting.EmploymentType, posting.ContactEmail, posting.URL, seen, seen); err != nil {
+			return fmt.Errorf("failed to save job posting %q: %w", posting.Title, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit job postings: %w", err)
+	}
+	return nil
+}
+
+// JobPostingFilter narrows the saved job postings returned by
+// JobPostings. Zero fields match everything.
+type JobPostingFilter struct {
+	RunID   int64
+	Since   time.Time
+	Company string
+}
+
+// JobPostings returns saved job postings, most recently posted first
+func (cr *Crawler) JobPostings(filter JobPostingFilter) ([]JobPosting, error) {
+	query := `SELECT jp.title, jp.organization, c.domain, jp.location, jp.date_posted, jp.employment_type,
+			jp.contact_email, jp.url, src.url, jp.last_seen
+		FROM job_postings jp
+		JOIN companies c ON c.id = jp.company_id
+		JOIN sources src ON src.id = jp.source_id
+		WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.RunID != 0 {
+		query += ` AND jp.run_id = ?`
+		args = append(args, filter.RunID)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND jp.last_seen >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Company != "" {
+		query += ` AND c.domain = ?`
+		args = append(args, registrableDomain(strings.TrimPrefix(filter.Company, "@")))
+	}
+	query += ` ORDER BY jp.date_posted DESC, jp.id`
+
+	rows, err := cr.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job postings: %w", err)
+	}
+	defer rows.Close()
+
+	var postings []JobPosting
+	for rows.Next() {
+		var p JobPosting
+		if err := rows.Scan(&p.Title, &p.Organization, &p.Company, &p.Location, &p.DatePosted, &p.EmploymentType,
+			&p.ContactEmail, &p.URL, &p.Source, &p.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to read job posting: %w", err)
+		}
+		postings = append(postings, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read job postings: %w", err)
+	}
+	return postings, nil
+}
+
+// ResultFilter narrows the saved results returned by Results. Zero
+// fields match everything.
+type ResultFilter struct {
+	RunID    int64
+	Since    time.Time
+	Domain   string
+	Location string
+	// Company keeps only emails of this company; see filterCompany
+	Company string
+	// Categories keeps only emails in these categories
+	Categories []string
+}
+
+// Results rebuilds saved results from the database, one per page,
+// location and query, in the order they were first found.
+func (cr *Crawler) Results(filter ResultFilter) ([]Result, error) {
+	query := `SELECT src.url, src.title, src.job_title, si.location, si.query, si.first_seen,
+			e.address, e.verification, COALESCE(c.domain, ''), si.category, si.snippet, si.heading
+		FROM sightings si
+		JOIN emails e ON e.id = si.email_id
+		JOIN sources src ON src.id = si.source_id
+		LEFT JOIN companies c ON c.id = si.company_id
+		WHERE 1 = 1`
+	var args []interface{}
+
+	if filter.RunID != 0 {
+		query += ` AND si.run_id = ?`
+		args = append(args, filter.RunID)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND si.last_seen >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Domain != "" {
+		// Match the domain itself and any of its subdomains
+		domain := strings.ToLower(strings.TrimPrefix(filter.Domain, "@"))
+		query += ` AND (LOWER(e.address) LIKE ? OR LOWER(e.address) LIKE ?)`
+		args = append(args, "%@"+domain, "%."+domain)
+	}
+	if filter.Location != "" {
+		query += ` AND si.location = ? COLLATE NOCASE`
+		args = append(args, filter.Location)
+	}
+	query += ` ORDER BY si.id`
+
+	rows, err := cr.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved results: %w", err)
+	}
+	defer rows.Close()
+
+	var saved []Result
+	index := make(map[string]int)
+	for rows.Next() {
+		var source, title, jobTitle, location, query, email, verification, company, category, snippet, heading string
+		var seen time.Time
+		if err := rows.Scan(&source, &title, &jobTitle, &location, &query, &seen, &email, &verification, &company, &category, &snippet, &heading); err != nil {
+			return nil, fmt.Errorf("failed to read saved result: %w", err)
+		}
+
+		key := source + "\x00" + location + "\x00" + query
+		i, ok := index[key]
+		if !ok {
+			i = len(saved)
+			index[key] = i
+			saved = append(saved, Result{
+				Location:  location,
+				Query:     query,
+				Timestamp: seen,
+				Source:    source,
+				PageTitle: title,
+				JobTitle:  jobTitle,
+				Details:   make(map[string]EmailDetail),
+			})
+		}
+		saved[i].Emails = append(saved[i].Emails, email)
+		// Sightings saved before classification are classified on load
+		if category == "" {
+			category = classifyEmail(email, "")
+		}
+		// Sightings saved before companies were recorded are assigned on load
+		if company == "" {
+			company = emailCompany(email, source)
+		}
+		saved[i].Details[email] = EmailDetail{Category: category, Snippet: snippet, Heading: heading, Verification: verification, Company: company}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read saved results: %w", err)
+	}
+	return filterCompany(filterCategories(saved, filter.Categories), filter.Company), nil
+}
@@ -0,0 +1,201 @@
+package careerfind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verification statuses of an email, from its syntax and its domain's DNS
+// records. No mail server is ever contacted.
+const (
+	// verifyValid: the domain has MX records
+	verifyValid = "valid"
+	// verifyNoMX: the domain has no MX records but an address record, which
+	// receives its mail (RFC 5321 section 5.1)
+	verifyNoMX = "no_mx"
+	// verifyUndeliverable: the domain does not exist, has no records, or
+	// publishes a null MX (RFC 7505)
+	verifyUndeliverable = "undeliverable"
+	// verifyInvalid: the address is malformed or a file name
+	verifyInvalid = "invalid"
+	// verifyUnknown: the lookup failed, or the email was never verified
+	verifyUnknown = "unknown"
+)
+
+// Length limits from RFC 5321 section 4.5.3.1
+const (
+	maxLocalPartLength = 64
+	maxDomainLength    = 253
+	maxAddressLength   = 254
+)
+
+var (
+	// dotAtomRegex matches an unquoted local part (RFC 5322 dot-atom)
+	dotAtomRegex     = regexp.MustCompile("^[a-zA-Z0-9!#$%&'*+/=?^_`{|}~-]+(\\.[a-zA-Z0-9!#$%&'*+/=?^_`{|}~-]+)*$")
+	domainLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+	// Top-level domains are alphabetic, or punycode for internationalized ones
+	topLevelRegex = regexp.MustCompile(`^([a-zA-Z]{2,63}|[xX][nN]--[a-zA-Z0-9-]{1,59})$`)
+	// retinaLabelRegex matches the scale suffix of image names like logo@2x.png
+	retinaLabelRegex = regexp.MustCompile(`^[0-9]+x$`)
+)
+
+// assetExtensions are file types whose names look like addresses, such as
+// logo@2x.png. None of them is a top-level domain.
+var assetExtensions = map[string]bool{
+	"png": true, "jpg": true, "jpeg": true, "gif": true, "svg": true, "webp": true,
+	"avif": true, "bmp": true, "ico": true, "tif": true, "tiff": true, "heic": true,
+	"css": true, "js": true, "mjs": true, "map": true, "json": true, "php": true,
+	"woff": true, "woff2": true, "ttf": true, "otf": true, "eot": true,
+	"mp3": true, "mp4": true, "webm": true, "wav": true, "pdf": true, "html": true, "htm": true,
+}
+
+// checkEmailSyntax reports why address is not a valid RFC 5321 mailbox.
+// Quoted local parts and address literals are not accepted, since they are
+// never published as contact addresses.
+func checkEmailSyntax(address string) error {
+	if len(address) > maxAddressLength {
+		return fmt.Errorf("address longer than %d characters", maxAddressLength)
+	}
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return errors.New("missing @")
+	}
+	local, domain := address[:at], address[at+1:]
+
+	if local == "" || len(local) > maxLocalPartLength {
+		return fmt.Errorf("local part must be 1 to %d characters", maxLocalPartLength)
+	}
+	if !dotAtomRegex.MatchString(local) {
+		return fmt.Errorf("invalid local part %q", local)
+	}
+
+	if domain == "" || len(domain) > maxDomainLength {
+		return fmt.Errorf("domain must be 1 to %d characters", maxDomainLength)
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("domain %q has no top-level domain", domain)
+	}
+	for _, label := range labels {
+		if !domainLabelRegex.MatchString(label) {
+			return fmt.Errorf("invalid domain label %q", label)
+		}
+	}
+	if !topLevelRegex.MatchString(labels[len(labels)-1]) {
+		return fmt.Errorf("invalid top-level domain %q", labels[len(labels)-1])
+	}
+	return nil
+}
+
+// isAssetName reports whether address is really a file name, such as an
+// image, stylesheet or script picked up from page markup.
+func isAssetName(address string) bool {
+	_, domain, _ := strings.Cut(address, "@")
+	labels := strings.Split(strings.ToLower(domain), ".")
+	return assetExtensions[labels[len(labels)-1]] || retinaLabelRegex.MatchString(labels[0])
+}
+
+// Resolver looks up the records that decide whether a domain receives
+// mail. *net.Resolver implements it.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// dnsTimeout bounds the lookups for one domain
+const dnsTimeout = 5 * time.Second
+
+// emailVerifier verifies emails against DNS, looking each domain up once
+type emailVerifier struct {
+	resolver Resolver
+	timeout  time.Duration
+	logger   *log.Logger
+
+	mu      sync.Mutex
+	domains map[string]string
+}
+
+func newEmailVerifier(resolver Resolver, timeout time.Duration, logger *log.Logger) *emailVerifier {
+	return &emailVerifier{
+		resolver: resolver,
+		timeout:  timeout,
+		logger:   logger,
+		domains:  make(map[string]string),
+	}
+}
+
+// verify returns the verification status of address
+func (v *emailVerifier) verify(ctx context.Context, address string) string {
+	if checkEmailSyntax(address) != nil || isAssetName(address) {
+		return verifyInvalid
+	}
+	_, domain, _ := strings.Cut(address, "@")
+	domain = strings.ToLower(domain)
+
+	v.mu.Lock()
+	status, ok := v.domains[domain]
+	v.mu.Unlock()
+	if ok {
+		return status
+	}
+
+	status = v.lookupDomain(ctx, domain)
+	// Failed lookups are retried for the next address at the domain
+	if status != verifyUnknown {
+		v.mu.Lock()
+		v.domains[domain] = status
+		v.mu.Unlock()
+	}
+	return status
+}
+
+func (v *emailVerifier) lookupDomain(ctx context.Context, domain string) string {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	// The resolver may return the valid records along with an error about
+	// invalid ones
+	records, err := v.resolver.LookupMX(ctx, domain+".")
+	if len(records) > 0 {
+		if len(records) == 1 && strings.Trim(records[0].Host, ".") == "" {
+			return verifyUndeliverable
+		}
+		return verifyValid
+	}
+	if err != nil && !isNotFound(err) {
+		v.logger.Printf("MX lookup for %s failed: %v", domain, err)
+		return verifyUnknown
+	}
+
+	hosts, err := v.resolver.LookupHost(ctx, domain+".")
+	if len(hosts) > 0 {
+		return verifyNoMX
+	}
+	if err != nil && !isNotFound(err) {
+		v.logger.Printf("Address lookup for %s failed: %v", domain, err)
+		return verifyUnknown
+	}
+	return verifyUndeliverable
+}
+
+// isNotFound reports whether a lookup failed because there are no records
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// verifyResult records the verification status of each email of result
+func (v *emailVerifier) verifyResult(ctx context.Context, result *Result) {
+	for _, email := range result.Emails {
+		detail := result.Details[email]
+		detail.Verification = v.verify(ctx, email)
+		result.Details[email] = detail
+	}
+}
@@ -0,0 +1,305 @@
+package careerfind
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The xlsx output is a minimal Office Open XML workbook written directly,
+// with one sheet per location or company and a row per email in the
+// columns of the CSV output.
+
+// xlsxSheet is one worksheet of the xlsx output
+type xlsxSheet struct {
+	Name    string
+	Results []Result
+}
+
+// Cell styles defined in xlsxStyles
+const (
+	xlsxStyleHeader = 1
+	xlsxStyleDate   = 2
+	xlsxStyleLink   = 3
+)
+
+// xlsxMaxURL is the longest hyperlink Excel accepts; longer source URLs are
+// written as plain text
+const xlsxMaxURL = 2079
+
+// xlsxSheets splits batch into sheets by location or company
+func xlsxSheets(group string, batch []Result) []xlsxSheet {
+	var sheets []xlsxSheet
+	if group == groupByCompanyName {
+		for _, company := range GroupByCompany(batch) {
+			sheets = append(sheets, xlsxSheet{Name: company.Name, Results: company.Results})
+		}
+	} else {
+		for _, g := range groupByLocation(batch) {
+			sheets = append(sheets, xlsxSheet{Name: g.Location, Results: g.Results})
+		}
+	}
+
+	used := make(map[string]bool)
+	for i := range sheets {
+		sheets[i].Name = xlsxSheetName(sheets[i].Name, used)
+	}
+	return sheets
+}
+
+// xlsxSheetName turns name into a valid worksheet name not yet in used:
+// at most 31 characters, none of []:*?/\ and unique ignoring case
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), "'")
+	if name == "" {
+		name = "Results"
+	}
+
+	base := truncateRunes(name, 31)
+	name = base
+	for n := 2; used[strings.ToLower(name)]; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		name = truncateRunes(base, 31-len(suffix)) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// saveXLSX writes batch to an xlsx workbook, one sheet per location or
+// company
+func saveXLSX(filename string, group string, batch []Result) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	if err := writeXLSX(file, xlsxSheets(group, batch)); err != nil {
+		return fmt.Errorf("failed to write xlsx: %w", err)
+	}
+	return nil
+}
+
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	z := zip.NewWriter(w)
+	files := []struct{ name, data string }{
+		{"[Content_Types].xml", xlsxContentTypes(len(sheets))},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(sheets))},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for i, sheet := range sheets {
+		data, rels := xlsxWorksheet(sheet)
+		files = append(files, struct{ name, data string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), data})
+		if rels != "" {
+			files = append(files, struct{ name, data string }{fmt.Sprintf("xl/worksheets/_rels/sheet%d.xml.rels", i+1), rels})
+		}
+	}
+
+	for _, f := range files {
+		part, err := z.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(part, f.data); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const xlsxRootRels = xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// xlsxStyles defines a bold header, a date format and a hyperlink font, in
+// the order of the xlsxStyle constants
+const xlsxStyles = xlsxHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/></numFmts>` +
+	`<fonts count="3"><font><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><u/><sz val="11"/><color rgb="FF0563C1"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="2" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+func xlsxContentTypes(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xlsxHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+func xlsxWorkbook(sheets []xlsxSheet) string {
+	var b strings.Builder
+	b.WriteString(xlsxHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sheet := range sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.Name), i+1, i+1)
+	}
+	b.WriteString(`</sheets><definedNames>`)
+	// Excel expects a hidden name for each auto-filter range
+	for i, sheet := range sheets {
+		fmt.Fprintf(&b, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`,
+			i, xmlEscape(strings.ReplaceAll(sheet.Name, "'", "''")), xlsxFilterRange(sheet, true))
+	}
+	b.WriteString(`</definedNames></workbook>`)
+	return b.String()
+}
+
+func xlsxWorkbookRels(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// xlsxFilterRange returns the header and data rows of a sheet, as A1:M9 or
+// with absolute references as $A$1:$M$9
+func xlsxFilterRange(sheet xlsxSheet, absolute bool) string {
+	rows := 1
+	for _, result := range sheet.Results {
+		rows += len(result.Emails)
+	}
+	last := xlsxColumn(len(emailColumns) - 1)
+	if absolute {
+		return fmt.Sprintf("$A$1:$%s$%d", last, rows)
+	}
+	return fmt.Sprintf("A1:%s%d", last, rows)
+}
+
+// xlsxWorksheet returns the worksheet XML of sheet and its relationships,
+// which hold the hyperlink targets, or "" if it has no links
+func xlsxWorksheet(sheet xlsxSheet) (string, string) {
+	var b, rels strings.Builder
+	b.WriteString(xlsxHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	// Keep the header in view while scrolling
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<sheetData>`)
+
+	b.WriteString(`<row r="1">`)
+	for col, name := range emailColumns {
+		xlsxStringCell(&b, col, 1, name, xlsxStyleHeader)
+	}
+	b.WriteString(`</row>`)
+
+	rels.WriteString(xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	var links strings.Builder
+	row, sourceCol, timestampCol := 1, columnIndex("Source"), columnIndex("Timestamp")
+	for _, result := range sheet.Results {
+		for _, email := range result.Emails {
+			row++
+			fmt.Fprintf(&b, `<row r="%d">`, row)
+			for col, value := range emailRow(result, email) {
+				switch {
+				case col == timestampCol && !result.Timestamp.IsZero():
+					fmt.Fprintf(&b, `<c r="%s%d" s="%d"><v>%s</v></c>`, xlsxColumn(col), row, xlsxStyleDate,
+						strconv.FormatFloat(excelSerial(result.Timestamp), 'f', -1, 64))
+				case col == sourceCol && value != "" && len(value) <= xlsxMaxURL:
+					xlsxStringCell(&b, col, row, value, xlsxStyleLink)
+					id := fmt.Sprintf("rId%d", row-1)
+					fmt.Fprintf(&links, `<hyperlink ref="%s%d" r:id="%s"/>`, xlsxColumn(col), row, id)
+					fmt.Fprintf(&rels, `<Relationship Id="%s" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`,
+						id, xmlEscape(value))
+				default:
+					xlsxStringCell(&b, col, row, value, 0)
+				}
+			}
+			b.WriteString(`</row>`)
+		}
+	}
+	b.WriteString(`</sheetData>`)
+
+	// autoFilter and hyperlinks must follow sheetData in this order
+	fmt.Fprintf(&b, `<autoFilter ref="%s"/>`, xlsxFilterRange(sheet, false))
+	if links.Len() == 0 {
+		b.WriteString(`</worksheet>`)
+		return b.String(), ""
+	}
+	b.WriteString(`<hyperlinks>` + links.String() + `</hyperlinks></worksheet>`)
+	rels.WriteString(`</Relationships>`)
+	return b.String(), rels.String()
+}
+
+// xlsxStringCell writes an inline string cell; empty values are skipped
+func xlsxStringCell(b *strings.Builder, col int, row int, value string, style int) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(b, `<c r="%s%d" t="inlineStr"`, xlsxColumn(col), row)
+	if style != 0 {
+		fmt.Fprintf(b, ` s="%d"`, style)
+	}
+	fmt.Fprintf(b, `><is><t xml:space="preserve">%s</t></is></c>`, xmlEscape(value))
+}
+
+// xlsxColumn returns the letters of a zero-based column index
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func columnIndex(name string) int {
+	for i, column := range emailColumns {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// excelSerial returns t as an Excel date: days since 1899-12-30 in t's time
+// zone, with the time of day as the fraction
+func excelSerial(t time.Time) float64 {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
+
+// xmlEscape escapes s for XML text and attributes, replacing characters
+// XML does not allow
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}